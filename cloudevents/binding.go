@@ -0,0 +1,108 @@
+package cloudevents
+
+import (
+	"context"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EventIDKey          = "cloudevents.event_id"
+	EventTypeKey        = "cloudevents.event_type"
+	EventSourceKey      = "cloudevents.event_source"
+	EventSpecVersionKey = "cloudevents.event_spec_version"
+	EventSubjectKey     = "cloudevents.event_subject"
+)
+
+// Attributes returns the span attributes describing event.
+func Attributes(event Event) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String(EventIDKey, event.ID),
+		attribute.String(EventTypeKey, event.Type),
+		attribute.String(EventSourceKey, event.Source),
+		attribute.String(EventSpecVersionKey, event.SpecVersion),
+	}
+	if event.Subject != "" {
+		attrs = append(attrs, attribute.String(EventSubjectKey, event.Subject))
+	}
+	return attrs
+}
+
+// Publisher publishes CloudEvents through an instrumented Publisher. Trace
+// context is still injected by the Publisher's Propagator, alongside the
+// CloudEvents headers.
+type Publisher struct {
+	publisher *instrumentation.Publisher
+	mode      Mode
+}
+
+func NewPublisher(publisher *instrumentation.Publisher, mode Mode) *Publisher {
+	if publisher == nil {
+		publisher = instrumentation.NewDefaultPublisher()
+	}
+	return &Publisher{
+		publisher: publisher,
+		mode:      mode,
+	}
+}
+
+func (p *Publisher) Publish(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	event Event,
+) error {
+	msg, err := Encode(event, p.mode)
+	if err != nil {
+		return err
+	}
+	ctx = instrumentation.ContextWithSpanStartOptions(ctx, trace.WithAttributes(Attributes(eventWithDefaults(event))...))
+	return p.publisher.Publish(ctx, channel, exchange, routingKey, mandatory, immediate, msg)
+}
+
+func (p *Publisher) PublishWithConfirm(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	event Event,
+) (*amqp091.DeferredConfirmation, error) {
+	msg, err := Encode(event, p.mode)
+	if err != nil {
+		return nil, err
+	}
+	ctx = instrumentation.ContextWithSpanStartOptions(ctx, trace.WithAttributes(Attributes(eventWithDefaults(event))...))
+	return p.publisher.PublishWithConfirm(ctx, channel, exchange, routingKey, mandatory, immediate, msg)
+}
+
+// Handler processes a decoded CloudEvent.
+type Handler func(ctx context.Context, event Event, delivery amqp091.Delivery) error
+
+// NewHandler adapts a Handler to an instrumentation.MessageHandler. The
+// event attributes are added to the consume span; deliveries that are not
+// valid CloudEvents are dead-lettered.
+func NewHandler(handler Handler) instrumentation.MessageHandler {
+	return func(ctx context.Context, delivery amqp091.Delivery) error {
+		span := trace.SpanFromContext(ctx)
+
+		event, err := Decode(&delivery)
+		if err != nil {
+			span.RecordError(err)
+			return instrumentation.DeadLetter(err)
+		}
+
+		span.SetAttributes(Attributes(event)...)
+		return handler(ctx, event, delivery)
+	}
+}
+
+func eventWithDefaults(event Event) Event {
+	if event.SpecVersion == "" {
+		event.SpecVersion = SpecVersion
+	}
+	return event
+}
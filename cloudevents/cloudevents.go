@@ -0,0 +1,387 @@
+// Package cloudevents implements the CloudEvents AMQP protocol binding on top
+// of the instrumented Publisher and Consumer.
+package cloudevents
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// HeaderPrefix prefixes CloudEvents attributes stored as application
+	// properties in binary mode.
+	HeaderPrefix = "cloudEvents:"
+	// StructuredContentType is the content type of structured mode messages.
+	StructuredContentType = "application/cloudevents+json"
+	SpecVersion           = "1.0"
+)
+
+var (
+	ErrNotCloudEvent = errors.New("message is not a CloudEvent")
+	ErrInvalidEvent  = errors.New("invalid CloudEvent")
+)
+
+// Mode selects how an event is mapped onto an AMQP message.
+type Mode int
+
+const (
+	// Binary stores attributes in prefixed headers and data in the body.
+	Binary Mode = iota
+	// Structured stores the whole event as JSON in the body.
+	Structured
+)
+
+func (m Mode) String() string {
+	switch m {
+	case Structured:
+		return "structured"
+	default:
+		return "binary"
+	}
+}
+
+type Event struct {
+	ID              string
+	Source          string
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string
+	Subject         string
+	Time            time.Time
+	// Extensions holds extension attributes keyed by their name, which must
+	// consist of lowercase letters and digits and must not be a context
+	// attribute such as id or type.
+	Extensions map[string]interface{}
+	Data       []byte
+}
+
+// Validate checks that the required context attributes are present.
+func (e Event) Validate() error {
+	var missing []string
+	if e.ID == "" {
+		missing = append(missing, "id")
+	}
+	if e.Source == "" {
+		missing = append(missing, "source")
+	}
+	if e.SpecVersion == "" {
+		missing = append(missing, "specversion")
+	}
+	if e.Type == "" {
+		missing = append(missing, "type")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInvalidEvent, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Encode maps event onto a publishing. An empty SpecVersion defaults to 1.0.
+func Encode(event Event, mode Mode) (amqp091.Publishing, error) {
+	if event.SpecVersion == "" {
+		event.SpecVersion = SpecVersion
+	}
+	if err := event.Validate(); err != nil {
+		return amqp091.Publishing{}, err
+	}
+	if err := validateExtensions(event.Extensions); err != nil {
+		return amqp091.Publishing{}, err
+	}
+
+	msg := amqp091.Publishing{
+		MessageId: event.ID,
+		Type:      event.Type,
+		Timestamp: event.Time,
+	}
+
+	if mode == Structured {
+		body, err := json.Marshal(toStructured(event))
+		if err != nil {
+			return amqp091.Publishing{}, fmt.Errorf("failed to encode structured CloudEvent: %w", err)
+		}
+		msg.ContentType = StructuredContentType
+		msg.Body = body
+		return msg, nil
+	}
+
+	headers := amqp091.Table{
+		HeaderPrefix + "id":          event.ID,
+		HeaderPrefix + "source":      event.Source,
+		HeaderPrefix + "specversion": event.SpecVersion,
+		HeaderPrefix + "type":        event.Type,
+	}
+	setIfNotEmpty(headers, "dataschema", event.DataSchema)
+	setIfNotEmpty(headers, "subject", event.Subject)
+	if !event.Time.IsZero() {
+		headers[HeaderPrefix+"time"] = event.Time.UTC().Format(time.RFC3339Nano)
+	}
+	for name, value := range event.Extensions {
+		headers[HeaderPrefix+name] = value
+	}
+	if err := headers.Validate(); err != nil {
+		return amqp091.Publishing{}, fmt.Errorf("failed to encode CloudEvent extensions: %w", err)
+	}
+
+	msg.Headers = headers
+	msg.ContentType = event.DataContentType
+	msg.Body = event.Data
+	return msg, nil
+}
+
+// Decode reads an event from a delivery in either mode. It returns
+// ErrNotCloudEvent when the delivery uses neither.
+func Decode(delivery *amqp091.Delivery) (Event, error) {
+	if mediaType(delivery.ContentType) == StructuredContentType {
+		var s structuredEvent
+		if err := json.Unmarshal(delivery.Body, &s); err != nil {
+			return Event{}, fmt.Errorf("failed to decode structured CloudEvent: %w", err)
+		}
+		event, err := s.event()
+		if err != nil {
+			return Event{}, err
+		}
+		return event, event.Validate()
+	}
+
+	if _, ok := delivery.Headers[HeaderPrefix+"specversion"]; !ok {
+		return Event{}, ErrNotCloudEvent
+	}
+
+	event := Event{
+		DataContentType: delivery.ContentType,
+		Data:            delivery.Body,
+	}
+	for key, value := range delivery.Headers {
+		name, ok := strings.CutPrefix(key, HeaderPrefix)
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(name)
+
+		switch name {
+		case "id":
+			event.ID = headerString(value)
+		case "source":
+			event.Source = headerString(value)
+		case "specversion":
+			event.SpecVersion = headerString(value)
+		case "type":
+			event.Type = headerString(value)
+		case "dataschema":
+			event.DataSchema = headerString(value)
+		case "subject":
+			event.Subject = headerString(value)
+		case "time":
+			t, err := headerTime(value)
+			if err != nil {
+				return Event{}, fmt.Errorf("failed to decode CloudEvent time: %w", err)
+			}
+			event.Time = t
+		default:
+			if event.Extensions == nil {
+				event.Extensions = make(map[string]interface{})
+			}
+			event.Extensions[name] = value
+		}
+	}
+
+	return event, event.Validate()
+}
+
+// validateExtensions rejects extension names that are not valid CloudEvents
+// attribute names or that would overwrite a context attribute.
+func validateExtensions(extensions map[string]interface{}) error {
+	for name := range extensions {
+		if structuredFields[name] {
+			return fmt.Errorf("%w: extension %q is a reserved attribute name", ErrInvalidEvent, name)
+		}
+		if !validAttributeName(name) {
+			return fmt.Errorf("%w: extension name %q must consist of lowercase letters and digits", ErrInvalidEvent, name)
+		}
+	}
+	return nil
+}
+
+func validAttributeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// structuredEvent is the JSON event format used in structured mode.
+type structuredEvent struct {
+	ID              string                 `json:"id"`
+	Source          string                 `json:"source"`
+	SpecVersion     string                 `json:"specversion"`
+	Type            string                 `json:"type"`
+	DataContentType string                 `json:"datacontenttype,omitempty"`
+	DataSchema      string                 `json:"dataschema,omitempty"`
+	Subject         string                 `json:"subject,omitempty"`
+	Time            *time.Time             `json:"time,omitempty"`
+	Data            json.RawMessage        `json:"data,omitempty"`
+	DataBase64      string                 `json:"data_base64,omitempty"`
+	Extensions      map[string]interface{} `json:"-"`
+}
+
+var structuredFields = map[string]bool{
+	"id": true, "source": true, "specversion": true, "type": true,
+	"datacontenttype": true, "dataschema": true, "subject": true, "time": true,
+	"data": true, "data_base64": true,
+}
+
+func toStructured(event Event) structuredEvent {
+	s := structuredEvent{
+		ID:              event.ID,
+		Source:          event.Source,
+		SpecVersion:     event.SpecVersion,
+		Type:            event.Type,
+		DataContentType: event.DataContentType,
+		DataSchema:      event.DataSchema,
+		Subject:         event.Subject,
+		Extensions:      event.Extensions,
+	}
+	if !event.Time.IsZero() {
+		t := event.Time.UTC()
+		s.Time = &t
+	}
+	if len(event.Data) > 0 {
+		if isJSON(event.DataContentType) && json.Valid(event.Data) {
+			s.Data = event.Data
+		} else {
+			s.DataBase64 = base64.StdEncoding.EncodeToString(event.Data)
+		}
+	}
+	return s
+}
+
+func (s structuredEvent) MarshalJSON() ([]byte, error) {
+	type plain structuredEvent
+	body, err := json.Marshal(plain(s))
+	if err != nil || len(s.Extensions) == 0 {
+		return body, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range s.Extensions {
+		name = strings.ToLower(name)
+		if structuredFields[name] {
+			continue
+		}
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = raw
+	}
+	return json.Marshal(fields)
+}
+
+func (s *structuredEvent) UnmarshalJSON(data []byte) error {
+	type plain structuredEvent
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for name, value := range fields {
+		if structuredFields[name] {
+			continue
+		}
+		if s.Extensions == nil {
+			s.Extensions = make(map[string]interface{})
+		}
+		s.Extensions[name] = value
+	}
+	return nil
+}
+
+func (s structuredEvent) event() (Event, error) {
+	event := Event{
+		ID:              s.ID,
+		Source:          s.Source,
+		SpecVersion:     s.SpecVersion,
+		Type:            s.Type,
+		DataContentType: s.DataContentType,
+		DataSchema:      s.DataSchema,
+		Subject:         s.Subject,
+		Extensions:      s.Extensions,
+	}
+	if s.Time != nil {
+		event.Time = *s.Time
+	}
+
+	switch {
+	case s.DataBase64 != "":
+		data, err := base64.StdEncoding.DecodeString(s.DataBase64)
+		if err != nil {
+			return Event{}, fmt.Errorf("failed to decode CloudEvent data_base64: %w", err)
+		}
+		event.Data = data
+	case len(s.Data) > 0:
+		event.Data = []byte(s.Data)
+		if event.DataContentType == "" {
+			event.DataContentType = "application/json"
+		}
+	}
+	return event, nil
+}
+
+func setIfNotEmpty(headers amqp091.Table, name, value string) {
+	if value != "" {
+		headers[HeaderPrefix+name] = value
+	}
+}
+
+func headerString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func headerTime(value interface{}) (time.Time, error) {
+	if t, ok := value.(time.Time); ok {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, headerString(value))
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	parsed, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return parsed
+}
+
+func isJSON(contentType string) bool {
+	mt := mediaType(contentType)
+	return mt == "" || mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json")
+}
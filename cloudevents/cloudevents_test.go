@@ -0,0 +1,206 @@
+package cloudevents
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type fakeAcknowledger struct {
+	acked, nacked int
+	requeue       bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked++
+	a.requeue = requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func deliveryFrom(msg amqp091.Publishing) amqp091.Delivery {
+	return amqp091.Delivery{
+		Headers:     msg.Headers,
+		ContentType: msg.ContentType,
+		MessageId:   msg.MessageId,
+		Type:        msg.Type,
+		Body:        msg.Body,
+	}
+}
+
+func testEvent() Event {
+	return Event{
+		ID:              "evt-1",
+		Source:          "/orders",
+		SpecVersion:     SpecVersion,
+		Type:            "com.example.order.created",
+		DataContentType: "application/json",
+		Subject:         "order-1",
+		Time:            time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Extensions:      map[string]interface{}{"tenant": "acme"},
+		Data:            []byte(`{"id":"order-1"}`),
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, mode := range []Mode{Binary, Structured} {
+		t.Run(mode.String(), func(t *testing.T) {
+			want := testEvent()
+			msg, err := Encode(want, mode)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if msg.MessageId != want.ID || msg.Type != want.Type {
+				t.Errorf("MessageId, Type = %q, %q", msg.MessageId, msg.Type)
+			}
+
+			delivery := deliveryFrom(msg)
+			got, err := Decode(&delivery)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Decode() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodeBinaryHeaders(t *testing.T) {
+	msg, err := Encode(testEvent(), Binary)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	if msg.Headers["cloudEvents:id"] != "evt-1" || msg.Headers["cloudEvents:tenant"] != "acme" {
+		t.Errorf("Headers = %v", msg.Headers)
+	}
+	if msg.Headers["cloudEvents:time"] != "2024-05-01T12:00:00Z" {
+		t.Errorf("time header = %v", msg.Headers["cloudEvents:time"])
+	}
+	if msg.ContentType != "application/json" || string(msg.Body) != `{"id":"order-1"}` {
+		t.Errorf("ContentType, Body = %q, %s", msg.ContentType, msg.Body)
+	}
+}
+
+func TestEncodeStructuredBinaryData(t *testing.T) {
+	event := testEvent()
+	event.DataContentType = "application/octet-stream"
+	event.Data = []byte{0xff, 0x00}
+
+	msg, err := Encode(event, Structured)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if msg.ContentType != StructuredContentType {
+		t.Errorf("ContentType = %q, want %q", msg.ContentType, StructuredContentType)
+	}
+
+	delivery := deliveryFrom(msg)
+	got, err := Decode(&delivery)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.Data, event.Data) {
+		t.Errorf("Data = %v, want %v", got.Data, event.Data)
+	}
+}
+
+func TestEncodeValidates(t *testing.T) {
+	_, err := Encode(Event{ID: "evt-1"}, Binary)
+	if !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("Encode() error = %v, want %v", err, ErrInvalidEvent)
+	}
+}
+
+func TestEncodeRejectsInvalidExtensionNames(t *testing.T) {
+	for _, name := range []string{"id", "source", "specversion", "type", "time", "datacontenttype", "data", "Tenant", "tenant-id", ""} {
+		for _, mode := range []Mode{Binary, Structured} {
+			event := testEvent()
+			event.Extensions = map[string]interface{}{name: "x"}
+			if _, err := Encode(event, mode); !errors.Is(err, ErrInvalidEvent) {
+				t.Errorf("Encode(%s) with extension %q error = %v, want %v", mode, name, err, ErrInvalidEvent)
+			}
+		}
+	}
+}
+
+func TestDecodeNotCloudEvent(t *testing.T) {
+	_, err := Decode(&amqp091.Delivery{ContentType: "application/json", Body: []byte(`{}`)})
+	if !errors.Is(err, ErrNotCloudEvent) {
+		t.Errorf("Decode() error = %v, want %v", err, ErrNotCloudEvent)
+	}
+}
+
+func TestHandlerRecordsEventAttributes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	consumer := instrumentation.NewConsumer(instrumentation.ConsumerConfig{Tracer: tracer})
+
+	msg, _ := Encode(testEvent(), Binary)
+	delivery := deliveryFrom(msg)
+	ack := &fakeAcknowledger{}
+	delivery.Acknowledger = ack
+
+	var got Event
+	err := consumer.ProcessDelivery(context.Background(), "orders", delivery,
+		NewHandler(func(ctx context.Context, event Event, delivery amqp091.Delivery) error {
+			got = event
+			return nil
+		}))
+	if err != nil {
+		t.Fatalf("ProcessDelivery() error = %v", err)
+	}
+	if got.ID != "evt-1" || ack.acked != 1 {
+		t.Errorf("event = %+v, ack = %+v", got, ack)
+	}
+
+	attrs := make(map[string]interface{})
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	for key, want := range map[string]string{
+		EventIDKey:     "evt-1",
+		EventTypeKey:   "com.example.order.created",
+		EventSourceKey: "/orders",
+	} {
+		if attrs[key] != want {
+			t.Errorf("%s = %v, want %v", key, attrs[key], want)
+		}
+	}
+}
+
+func TestHandlerDeadLettersInvalidEvents(t *testing.T) {
+	consumer := instrumentation.NewDefaultConsumer()
+
+	ack := &fakeAcknowledger{}
+	called := false
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: ack, Body: []byte("plain")},
+		NewHandler(func(ctx context.Context, event Event, delivery amqp091.Delivery) error {
+			called = true
+			return nil
+		}))
+
+	if called {
+		t.Error("handler should not be called for non-CloudEvents")
+	}
+	if ack.nacked != 1 || ack.requeue {
+		t.Errorf("ack = %+v, want dead-lettered", ack)
+	}
+}
@@ -0,0 +1,61 @@
+package codec
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	ID     string
+	Amount float64
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, c := range []Codec{JSON, Gob, Msgpack} {
+		t.Run(c.ContentType(), func(t *testing.T) {
+			data, err := c.Marshal(order{ID: "o-1", Amount: 9.99})
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var got order
+			if err := c.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if got != (order{ID: "o-1", Amount: 9.99}) {
+				t.Errorf("Unmarshal() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestProtobufCodec(t *testing.T) {
+	data, err := Protobuf.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var direct wrapperspb.StringValue
+	if err := Protobuf.Unmarshal(data, &direct); err != nil || direct.GetValue() != "hello" {
+		t.Errorf("Unmarshal(message) = %q, %v", direct.GetValue(), err)
+	}
+
+	var pointer *wrapperspb.StringValue
+	if err := Protobuf.Unmarshal(data, &pointer); err != nil || pointer.GetValue() != "hello" {
+		t.Errorf("Unmarshal(pointer to message pointer) = %q, %v", pointer.GetValue(), err)
+	}
+
+	if _, err := Protobuf.Marshal(order{}); err == nil {
+		t.Error("Marshal() should reject non-proto values")
+	}
+}
+
+func TestForContentType(t *testing.T) {
+	if c, ok := ForContentType("application/json; charset=utf-8"); !ok || c != JSON {
+		t.Errorf("ForContentType(json) = %v, %v", c, ok)
+	}
+	if _, ok := ForContentType("text/plain"); ok {
+		t.Error("ForContentType(text/plain) should not resolve a codec")
+	}
+}
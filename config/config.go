@@ -0,0 +1,352 @@
+// Package config builds orb configuration from ORB_* environment variables,
+// the standard OTEL_* variables and an optional YAML or JSON file.
+//
+// Values are applied in order: defaults, then the file, then the
+// environment. The file is read from the path given to Load or, when that
+// is empty, from ORB_CONFIG_FILE:
+//
+//	cfg, err := config.Load("")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	conn, err := orb.Open(ctx, cfg.URL, cfg.Options()...)
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	"github.com/startower-observability/orb/internal"
+	"github.com/startower-observability/orb/sampling"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gopkg.in/yaml.v3"
+)
+
+// ValidationError names the file key or environment variable holding an
+// invalid value.
+type ValidationError struct {
+	Key string
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %v", e.Key, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+type Config struct {
+	URL            string `json:"url" yaml:"url"`
+	ConnectionName string `json:"connection_name" yaml:"connection_name"`
+	// SemconvMode is "old", "stable" or "dup". When empty the mode follows
+	// OTEL_SEMCONV_STABILITY_OPT_IN.
+	SemconvMode string `json:"semconv_mode" yaml:"semconv_mode"`
+	// Propagators uses the OTEL_PROPAGATORS names. "tracecontext",
+	// "baggage" and "none" are built in. Other names, such as "b3", leave
+	// the global propagator in use; see UnsupportedPropagators.
+	Propagators   []string    `json:"propagators" yaml:"propagators"`
+	BodyCapture   BodyCapture `json:"body_capture" yaml:"body_capture"`
+	HeaderCapture []string    `json:"header_capture" yaml:"header_capture"`
+	Sampling      Sampling    `json:"sampling" yaml:"sampling"`
+	Filters       Filters     `json:"filters" yaml:"filters"`
+
+	// keys maps fields to the file key or environment variable they were
+	// last set from, so validation errors name the right source.
+	keys map[string]string
+	// envRules counts the sampling rules read from ORB_SAMPLING_QUEUES,
+	// which precede the rules from the file.
+	envRules int
+}
+
+type BodyCapture struct {
+	Enabled      bool     `json:"enabled" yaml:"enabled"`
+	MaxSize      int      `json:"max_size" yaml:"max_size"`
+	ContentTypes []string `json:"content_types" yaml:"content_types"`
+}
+
+type Sampling struct {
+	Rules                    []SamplingRule `json:"rules" yaml:"rules"`
+	AlwaysSampleRedelivered  bool           `json:"always_sample_redelivered" yaml:"always_sample_redelivered"`
+	AlwaysSampleDeadLettered bool           `json:"always_sample_dead_lettered" yaml:"always_sample_dead_lettered"`
+	ParentBased              bool           `json:"parent_based" yaml:"parent_based"`
+}
+
+type SamplingRule struct {
+	Queue      string  `json:"queue" yaml:"queue"`
+	Exchange   string  `json:"exchange" yaml:"exchange"`
+	RoutingKey string  `json:"routing_key" yaml:"routing_key"`
+	Ratio      float64 `json:"ratio" yaml:"ratio"`
+}
+
+type Filters struct {
+	// SkipQueues and SkipExchanges are path.Match patterns for destinations
+	// that are never traced.
+	SkipQueues    []string `json:"skip_queues" yaml:"skip_queues"`
+	SkipExchanges []string `json:"skip_exchanges" yaml:"skip_exchanges"`
+	// RecordOnErrorOrSlow only records spans for failed operations and
+	// those slower than LatencyThreshold, a time.ParseDuration string.
+	RecordOnErrorOrSlow bool   `json:"record_on_error_or_slow" yaml:"record_on_error_or_slow"`
+	LatencyThreshold    string `json:"latency_threshold" yaml:"latency_threshold"`
+}
+
+// Load reads the file at path, or ORB_CONFIG_FILE when path is empty, then
+// applies the environment and validates the result.
+func Load(path string) (*Config, error) {
+	cfg := &Config{keys: make(map[string]string)}
+
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(c); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate checks every value and returns a *ValidationError for the first
+// invalid one.
+func (c *Config) Validate() error {
+	if c.URL != "" {
+		if _, err := amqp091.ParseURI(c.URL); err != nil {
+			return c.invalid("url", err)
+		}
+	}
+	if _, ok := internal.ParseSemconvMode(c.SemconvMode); !ok {
+		return c.invalid("semconv_mode", fmt.Errorf("unknown mode %q, want old, stable or dup", c.SemconvMode))
+	}
+	if c.BodyCapture.MaxSize < 0 {
+		return c.invalid("body_capture.max_size", errors.New("must not be negative"))
+	}
+	for i, rule := range c.Sampling.Rules[c.envRules:] {
+		if err := rule.validate(); err != nil {
+			return c.invalid(fmt.Sprintf("sampling.rules[%d]", i), err)
+		}
+	}
+	for _, list := range []struct {
+		key      string
+		patterns []string
+	}{
+		{"filters.skip_queues", c.Filters.SkipQueues},
+		{"filters.skip_exchanges", c.Filters.SkipExchanges},
+	} {
+		for _, pattern := range list.patterns {
+			if err := validatePattern(pattern); err != nil {
+				return c.invalid(list.key, err)
+			}
+		}
+	}
+	if c.Filters.LatencyThreshold != "" {
+		if d, err := time.ParseDuration(c.Filters.LatencyThreshold); err != nil || d < 0 {
+			return c.invalid("filters.latency_threshold", fmt.Errorf("%q is not a positive duration", c.Filters.LatencyThreshold))
+		}
+	}
+	return nil
+}
+
+func (c *Config) invalid(key string, err error) error {
+	if source, ok := c.keys[key]; ok {
+		key = source
+	}
+	return &ValidationError{Key: key, Err: err}
+}
+
+func (r SamplingRule) validate() error {
+	if r.Ratio < 0 || r.Ratio > 1 {
+		return fmt.Errorf("ratio %v is outside [0, 1]", r.Ratio)
+	}
+	for _, pattern := range []string{r.Queue, r.Exchange, r.RoutingKey} {
+		if err := validatePattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePattern(pattern string) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("bad pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// ConnectionConfig builds the connection configuration. The publisher and
+// consumer share the body and header capture, filters and propagator.
+func (c *Config) ConnectionConfig() instrumentation.ConnectionConfig {
+	mode, _ := internal.ParseSemconvMode(c.SemconvMode)
+	threshold, _ := time.ParseDuration(c.Filters.LatencyThreshold)
+
+	publisher := instrumentation.PublisherConfig{
+		SemconvMode:      mode,
+		LatencyThreshold: threshold,
+		Filter:           c.publishFilter(),
+	}
+	consumer := instrumentation.ConsumerConfig{
+		SemconvMode:      mode,
+		LatencyThreshold: threshold,
+		Filter:           c.consumeFilter(),
+	}
+
+	if c.BodyCapture.Enabled {
+		capture := &instrumentation.BodyCaptureConfig{
+			MaxSize:      c.BodyCapture.MaxSize,
+			ContentTypes: c.BodyCapture.ContentTypes,
+		}
+		publisher.BodyCapture = capture
+		consumer.BodyCapture = capture
+	}
+	if len(c.HeaderCapture) > 0 {
+		capture := &instrumentation.HeaderCaptureConfig{Headers: c.HeaderCapture}
+		publisher.HeaderCapture = capture
+		consumer.HeaderCapture = capture
+	}
+	if textMap := c.TextMapPropagator(); textMap != nil {
+		propagator := instrumentation.NewPropagatorWithConfig(instrumentation.PropagatorConfig{
+			TextMapPropagator: textMap,
+		})
+		publisher.Propagator = propagator
+		consumer.Propagator = propagator
+	}
+
+	return instrumentation.ConnectionConfig{
+		ChannelConfig: instrumentation.ChannelConfig{
+			PublisherConfig: publisher,
+			ConsumerConfig:  consumer,
+		},
+	}
+}
+
+// Options returns the options for instrumentation.Open.
+func (c *Config) Options() []instrumentation.Option {
+	opts := []instrumentation.Option{instrumentation.WithConnectionConfig(c.ConnectionConfig())}
+	if c.ConnectionName != "" {
+		opts = append(opts, instrumentation.WithConnectionName(c.ConnectionName))
+	}
+	return opts
+}
+
+// Sampler builds a sampling.NewSampler from the sampling rules.
+func (c *Config) Sampler() sdktrace.Sampler {
+	rules := make([]sampling.Rule, 0, len(c.Sampling.Rules))
+	for _, rule := range c.Sampling.Rules {
+		rules = append(rules, sampling.Rule{
+			Queue:      rule.Queue,
+			Exchange:   rule.Exchange,
+			RoutingKey: rule.RoutingKey,
+			Ratio:      rule.Ratio,
+		})
+	}
+	return sampling.NewSampler(sampling.Config{
+		Rules:                    rules,
+		AlwaysSampleRedelivered:  c.Sampling.AlwaysSampleRedelivered,
+		AlwaysSampleDeadLettered: c.Sampling.AlwaysSampleDeadLettered,
+		ParentBased:              c.Sampling.ParentBased,
+	})
+}
+
+// TextMapPropagator returns the propagator selected by Propagators, or nil
+// when the global propagator should be used: when none is configured, or
+// when the list names propagators this package cannot build. The global
+// propagator is then expected to be set up from the same OTEL_PROPAGATORS.
+func (c *Config) TextMapPropagator() propagation.TextMapPropagator {
+	if len(c.Propagators) == 0 || len(c.UnsupportedPropagators()) > 0 {
+		return nil
+	}
+	var propagators []propagation.TextMapPropagator
+	for _, name := range c.Propagators {
+		switch strings.TrimSpace(name) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// UnsupportedPropagators returns the Propagators entries that are not built
+// in, such as "b3", "jaeger" or "xray".
+func (c *Config) UnsupportedPropagators() []string {
+	var unsupported []string
+	for _, name := range c.Propagators {
+		switch strings.TrimSpace(name) {
+		case "tracecontext", "baggage", "none":
+		default:
+			unsupported = append(unsupported, name)
+		}
+	}
+	return unsupported
+}
+
+func (c *Config) publishFilter() instrumentation.PublishFilter {
+	if len(c.Filters.SkipExchanges) == 0 && !c.Filters.RecordOnErrorOrSlow {
+		return nil
+	}
+	return func(exchange, routingKey string, msg *amqp091.Publishing) instrumentation.FilterMode {
+		return c.filterMode(c.Filters.SkipExchanges, exchange)
+	}
+}
+
+func (c *Config) consumeFilter() instrumentation.ConsumeFilter {
+	if len(c.Filters.SkipQueues) == 0 && !c.Filters.RecordOnErrorOrSlow {
+		return nil
+	}
+	return func(queueName string, delivery *amqp091.Delivery) instrumentation.FilterMode {
+		return c.filterMode(c.Filters.SkipQueues, queueName)
+	}
+}
+
+func (c *Config) filterMode(skip []string, destination string) instrumentation.FilterMode {
+	for _, pattern := range skip {
+		if ok, _ := path.Match(pattern, destination); ok {
+			return instrumentation.FilterSkip
+		}
+	}
+	if c.Filters.RecordOnErrorOrSlow {
+		return instrumentation.FilterRecordOnErrorOrSlow
+	}
+	return instrumentation.FilterRecord
+}
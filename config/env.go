@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/startower-observability/orb/internal"
+)
+
+const (
+	EnvConfigFile             = "ORB_CONFIG_FILE"
+	EnvURL                    = "ORB_URL"
+	EnvConnectionName         = "ORB_CONNECTION_NAME"
+	EnvSemconvMode            = "ORB_SEMCONV_MODE"
+	EnvBodyCapture            = "ORB_BODY_CAPTURE"
+	EnvBodyCaptureMaxSize     = "ORB_BODY_CAPTURE_MAX_SIZE"
+	EnvBodyCaptureTypes       = "ORB_BODY_CAPTURE_CONTENT_TYPES"
+	EnvHeaderCapture          = "ORB_HEADER_CAPTURE"
+	EnvSamplingQueues         = "ORB_SAMPLING_QUEUES"
+	EnvSamplingRedelivered    = "ORB_SAMPLING_ALWAYS_REDELIVERED"
+	EnvSamplingDeadLettered   = "ORB_SAMPLING_ALWAYS_DEAD_LETTERED"
+	EnvSamplingParentBased    = "ORB_SAMPLING_PARENT_BASED"
+	EnvFilterSkipQueues       = "ORB_FILTER_SKIP_QUEUES"
+	EnvFilterSkipExchanges    = "ORB_FILTER_SKIP_EXCHANGES"
+	EnvFilterRecordOnError    = "ORB_FILTER_RECORD_ON_ERROR_OR_SLOW"
+	EnvFilterLatencyThreshold = "ORB_FILTER_LATENCY_THRESHOLD"
+
+	EnvOTelPropagators = "OTEL_PROPAGATORS"
+)
+
+func (c *Config) loadEnv() error {
+	c.setString(EnvURL, "url", &c.URL)
+	c.setString(EnvConnectionName, "connection_name", &c.ConnectionName)
+
+	if !c.setString(EnvSemconvMode, "semconv_mode", &c.SemconvMode) && c.SemconvMode == "" {
+		if value, ok := os.LookupEnv(internal.SemconvStabilityOptInEnv); ok {
+			c.SemconvMode = internal.SemconvModeFromOptIn(value).String()
+			c.keys["semconv_mode"] = internal.SemconvStabilityOptInEnv
+		}
+	}
+	c.setList(EnvOTelPropagators, "propagators", &c.Propagators)
+
+	if err := c.setBool(EnvBodyCapture, &c.BodyCapture.Enabled); err != nil {
+		return err
+	}
+	if err := c.setInt(EnvBodyCaptureMaxSize, "body_capture.max_size", &c.BodyCapture.MaxSize); err != nil {
+		return err
+	}
+	c.setList(EnvBodyCaptureTypes, "body_capture.content_types", &c.BodyCapture.ContentTypes)
+	c.setList(EnvHeaderCapture, "header_capture", &c.HeaderCapture)
+
+	if err := c.setSamplingQueues(); err != nil {
+		return err
+	}
+	for _, b := range []struct {
+		env   string
+		value *bool
+	}{
+		{EnvSamplingRedelivered, &c.Sampling.AlwaysSampleRedelivered},
+		{EnvSamplingDeadLettered, &c.Sampling.AlwaysSampleDeadLettered},
+		{EnvSamplingParentBased, &c.Sampling.ParentBased},
+		{EnvFilterRecordOnError, &c.Filters.RecordOnErrorOrSlow},
+	} {
+		if err := c.setBool(b.env, b.value); err != nil {
+			return err
+		}
+	}
+
+	c.setList(EnvFilterSkipQueues, "filters.skip_queues", &c.Filters.SkipQueues)
+	c.setList(EnvFilterSkipExchanges, "filters.skip_exchanges", &c.Filters.SkipExchanges)
+	c.setString(EnvFilterLatencyThreshold, "filters.latency_threshold", &c.Filters.LatencyThreshold)
+	return nil
+}
+
+// setSamplingQueues reads per-queue ratios such as "health-*=0,orders=0.5".
+// They are evaluated before the rules from the file.
+func (c *Config) setSamplingQueues() error {
+	value, ok := os.LookupEnv(EnvSamplingQueues)
+	if !ok || value == "" {
+		return nil
+	}
+
+	var rules []SamplingRule
+	for _, item := range splitList(value) {
+		queue, ratio, found := strings.Cut(item, "=")
+		if !found {
+			return &ValidationError{Key: EnvSamplingQueues, Err: fmt.Errorf("%q is not queue=ratio", item)}
+		}
+		r, err := strconv.ParseFloat(strings.TrimSpace(ratio), 64)
+		if err != nil {
+			return &ValidationError{Key: EnvSamplingQueues, Err: fmt.Errorf("ratio for %q: %w", queue, err)}
+		}
+		rule := SamplingRule{Queue: strings.TrimSpace(queue), Ratio: r}
+		if err := rule.validate(); err != nil {
+			return &ValidationError{Key: EnvSamplingQueues, Err: err}
+		}
+		rules = append(rules, rule)
+	}
+	c.envRules = len(rules)
+	c.Sampling.Rules = append(rules, c.Sampling.Rules...)
+	return nil
+}
+
+func (c *Config) setString(env, key string, target *string) bool {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return false
+	}
+	*target = value
+	c.keys[key] = env
+	return true
+}
+
+func (c *Config) setList(env, key string, target *[]string) {
+	if value, ok := os.LookupEnv(env); ok {
+		*target = splitList(value)
+		c.keys[key] = env
+	}
+}
+
+func (c *Config) setBool(env string, target *bool) error {
+	value, ok := os.LookupEnv(env)
+	if !ok || value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return &ValidationError{Key: env, Err: fmt.Errorf("%q is not a boolean", value)}
+	}
+	*target = b
+	return nil
+}
+
+func (c *Config) setInt(env, key string, target *int) error {
+	value, ok := os.LookupEnv(env)
+	if !ok || value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return &ValidationError{Key: env, Err: fmt.Errorf("%q is not an integer", value)}
+	}
+	*target = n
+	c.keys[key] = env
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
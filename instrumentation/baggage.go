@@ -0,0 +1,100 @@
+package instrumentation
+
+import (
+	"context"
+	"sort"
+
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultBaggageMaxSize and DefaultBaggageMaxMembers are the W3C baggage
+	// limits.
+	DefaultBaggageMaxSize    = 8192
+	DefaultBaggageMaxMembers = 180
+
+	baggageHeader = "baggage"
+)
+
+type BaggageConfig struct {
+	// Promote lists baggage members recorded as "baggage.<key>" attributes on
+	// publish and consume spans.
+	Promote []string
+	// MaxSize bounds the encoded baggage header in bytes. Members are dropped
+	// in key order once the limit is reached. Defaults to
+	// DefaultBaggageMaxSize.
+	MaxSize int
+	// MaxMembers defaults to DefaultBaggageMaxMembers.
+	MaxMembers int
+}
+
+func (c BaggageConfig) withDefaults() BaggageConfig {
+	if c.MaxSize <= 0 {
+		c.MaxSize = DefaultBaggageMaxSize
+	}
+	if c.MaxMembers <= 0 {
+		c.MaxMembers = DefaultBaggageMaxMembers
+	}
+	return c
+}
+
+// limit replaces the baggage in ctx with one that fits the configured limits
+// and flags the span in ctx when members had to be dropped.
+func (c *BaggageConfig) limit(ctx context.Context) context.Context {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return ctx
+	}
+	if bag.Len() <= c.MaxMembers && len(bag.String()) <= c.MaxSize {
+		return ctx
+	}
+
+	members := bag.Members()
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Key() < members[j].Key()
+	})
+
+	kept := make([]baggage.Member, 0, len(members))
+	size := 0
+	for _, member := range members {
+		memberSize := len(member.String())
+		if len(kept) > 0 {
+			memberSize++ // separator
+		}
+		if len(kept) == c.MaxMembers || size+memberSize > c.MaxSize {
+			continue
+		}
+		kept = append(kept, member)
+		size += memberSize
+	}
+
+	limited, err := baggage.New(kept...)
+	if err != nil {
+		return baggage.ContextWithoutBaggage(ctx)
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int(internal.MessagingOrbBaggageDropped, len(members)-len(kept)),
+	)
+	return baggage.ContextWithBaggage(ctx, limited)
+}
+
+func (p *Propagator) baggageAttributes(ctx context.Context) []attribute.KeyValue {
+	if p.config.Baggage == nil || len(p.config.Baggage.Promote) == 0 {
+		return nil
+	}
+
+	bag := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, key := range p.config.Baggage.Promote {
+		member := bag.Member(key)
+		if member.Key() == "" {
+			continue
+		}
+		attrs = append(attrs, attribute.String(internal.BaggageAttributePrefix+key, member.Value()))
+	}
+	return attrs
+}
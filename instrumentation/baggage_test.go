@@ -0,0 +1,121 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func contextWithBaggage(t *testing.T, members map[string]string) context.Context {
+	t.Helper()
+	bag := baggage.Baggage{}
+	for key, value := range members {
+		member, err := baggage.NewMember(key, value)
+		if err != nil {
+			t.Fatalf("NewMember() error = %v", err)
+		}
+		if bag, err = bag.SetMember(member); err != nil {
+			t.Fatalf("SetMember() error = %v", err)
+		}
+	}
+	return baggage.ContextWithBaggage(context.Background(), bag)
+}
+
+func TestPropagatorBaggageWithoutGlobalSupport(t *testing.T) {
+	p := NewPropagatorWithConfig(PropagatorConfig{
+		TextMapPropagator: propagation.TraceContext{},
+		Baggage:           &BaggageConfig{},
+	})
+
+	headers := amqp091.Table{}
+	p.InjectToHeaders(contextWithBaggage(t, map[string]string{"tenant": "acme"}), headers)
+	if headers["baggage"] != "tenant=acme" {
+		t.Fatalf("baggage header = %v, want tenant=acme", headers["baggage"])
+	}
+
+	ctx := p.ExtractFromHeaders(context.Background(), headers)
+	if got := baggage.FromContext(ctx).Member("tenant").Value(); got != "acme" {
+		t.Errorf("extracted tenant = %q, want acme", got)
+	}
+
+	plain := NewPropagatorWithConfig(PropagatorConfig{TextMapPropagator: propagation.TraceContext{}})
+	headers = amqp091.Table{}
+	plain.InjectToHeaders(contextWithBaggage(t, map[string]string{"tenant": "acme"}), headers)
+	if _, ok := headers["baggage"]; ok {
+		t.Error("baggage should only be propagated when configured")
+	}
+}
+
+func TestPropagatorBaggageLimits(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	p := NewPropagatorWithConfig(PropagatorConfig{
+		TextMapPropagator: propagation.TraceContext{},
+		Baggage:           &BaggageConfig{MaxMembers: 2},
+	})
+
+	ctx := contextWithBaggage(t, map[string]string{"a": "1", "b": "2", "c": "3"})
+	ctx, span := tracer.Start(ctx, "publish")
+	headers := amqp091.Table{}
+	p.InjectToHeaders(ctx, headers)
+	span.End()
+
+	bag, err := baggage.Parse(headers["baggage"].(string))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if bag.Len() != 2 || bag.Member("c").Key() != "" {
+		t.Errorf("baggage = %v, want a and b only", bag)
+	}
+
+	dropped := false
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		if string(attr.Key) == internal.MessagingOrbBaggageDropped && attr.Value.AsInt64() == 1 {
+			dropped = true
+		}
+	}
+	if !dropped {
+		t.Error("span should record the dropped baggage members")
+	}
+
+	size := NewPropagatorWithConfig(PropagatorConfig{Baggage: &BaggageConfig{MaxSize: 8}})
+	limited := size.config.Baggage.limit(contextWithBaggage(t, map[string]string{"a": "1", "b": "2", "c": "3"}))
+	if got := baggage.FromContext(limited).String(); len(got) > 8 {
+		t.Errorf("baggage = %q, want at most 8 bytes", got)
+	}
+}
+
+func TestBaggagePromotedToSpans(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	propagator := NewPropagatorWithConfig(PropagatorConfig{
+		TextMapPropagator: propagation.TraceContext{},
+		Baggage:           &BaggageConfig{Promote: []string{"tenant", "tier"}},
+	})
+	publisher := NewPublisher(PublisherConfig{Tracer: tracer, Propagator: propagator})
+	consumer := NewConsumer(ConsumerConfig{Tracer: tracer, Propagator: propagator})
+
+	ctx := contextWithBaggage(t, map[string]string{"tenant": "acme", "user": "u-1"})
+	var sent []sentMessage
+	if err := publisher.publish(ctx, "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, Headers: sent[0].msg.Headers},
+		func(ctx context.Context, delivery amqp091.Delivery) error { return nil })
+
+	for _, span := range recorder.Ended() {
+		attrs := make(map[string]interface{})
+		for _, attr := range span.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.AsInterface()
+		}
+		if attrs["baggage.tenant"] != "acme" {
+			t.Errorf("%s: baggage.tenant = %v, want acme", span.Name(), attrs["baggage.tenant"])
+		}
+		if _, ok := attrs["baggage.user"]; ok {
+			t.Errorf("%s: only configured members should be promoted", span.Name())
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package instrumentation
+
+import (
+	"encoding/base64"
+	"mime"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultBodyCaptureMaxSize = 4096
+
+// BodyCaptureConfig enables recording message bodies as span events. Bodies
+// whose content type is not textual are recorded base64 encoded.
+type BodyCaptureConfig struct {
+	// MaxSize limits the number of body bytes recorded. Defaults to
+	// DefaultBodyCaptureMaxSize.
+	MaxSize int
+	// ContentTypes restricts capture to matching media types, e.g.
+	// "application/json" or "text/*". An empty list captures every body.
+	ContentTypes []string
+	// Redactor may mask sensitive data before the body reaches the exporter.
+	Redactor func(contentType string, body []byte) []byte
+}
+
+func (c *BodyCaptureConfig) record(span trace.Span, contentType string, body []byte) {
+	if !span.IsRecording() {
+		return
+	}
+	if attrs, ok := c.attributes(contentType, body); ok {
+		span.AddEvent(internal.MessageBodyEvent, trace.WithAttributes(attrs...))
+	}
+}
+
+func (c *BodyCaptureConfig) attributes(contentType string, body []byte) ([]attribute.KeyValue, bool) {
+	mediaType := parseMediaType(contentType)
+	if !c.allows(mediaType) {
+		return nil, false
+	}
+
+	size := len(body)
+	if c.Redactor != nil {
+		body = c.Redactor(contentType, body)
+	}
+
+	maxSize := c.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultBodyCaptureMaxSize
+	}
+
+	text := isTextMediaType(mediaType, body)
+
+	truncated := false
+	if len(body) > maxSize {
+		body = body[:maxSize]
+		truncated = true
+	}
+
+	var value, encoding string
+	if text {
+		if truncated {
+			body = trimPartialRune(body)
+		}
+		value, encoding = string(body), internal.BodyEncodingText
+	} else {
+		value, encoding = base64.StdEncoding.EncodeToString(body), internal.BodyEncodingBase64
+	}
+
+	return []attribute.KeyValue{
+		attribute.String(internal.MessagingMessageBody, value),
+		attribute.Int(internal.MessagingMessageBodySize, size),
+		attribute.String(internal.MessagingMessageBodyEncoding, encoding),
+		attribute.Bool(internal.MessagingMessageBodyTruncated, truncated),
+	}, true
+}
+
+func (c *BodyCaptureConfig) allows(mediaType string) bool {
+	if len(c.ContentTypes) == 0 {
+		return true
+	}
+	for _, pattern := range c.ContentTypes {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func parseMediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mediaType
+}
+
+func isTextMediaType(mediaType string, body []byte) bool {
+	switch {
+	case mediaType == "":
+		return utf8.Valid(body)
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded",
+		"application/javascript", "application/yaml", "application/x-yaml":
+		return true
+	}
+	return false
+}
+
+func trimPartialRune(body []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(body); i++ {
+		if utf8.RuneStart(body[len(body)-i]) {
+			if !utf8.FullRune(body[len(body)-i:]) {
+				return body[:len(body)-i]
+			}
+			return body
+		}
+	}
+	return body
+}
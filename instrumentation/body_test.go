@@ -0,0 +1,103 @@
+package instrumentation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/startower-observability/orb/internal"
+)
+
+func bodyAttributes(t *testing.T, config *BodyCaptureConfig, contentType string, body []byte) (map[string]interface{}, bool) {
+	t.Helper()
+
+	attrs, ok := config.attributes(contentType, body)
+	found := make(map[string]interface{})
+	for _, attr := range attrs {
+		found[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	return found, ok
+}
+
+func TestBodyCaptureText(t *testing.T) {
+	found, ok := bodyAttributes(t, &BodyCaptureConfig{}, "application/json; charset=utf-8", []byte(`{"id":1}`))
+	if !ok {
+		t.Fatal("attributes() should capture JSON bodies")
+	}
+
+	if found[internal.MessagingMessageBody] != `{"id":1}` {
+		t.Errorf("body = %v, want raw JSON", found[internal.MessagingMessageBody])
+	}
+	if found[internal.MessagingMessageBodyEncoding] != internal.BodyEncodingText {
+		t.Errorf("encoding = %v, want %v", found[internal.MessagingMessageBodyEncoding], internal.BodyEncodingText)
+	}
+	if found[internal.MessagingMessageBodySize] != int64(8) {
+		t.Errorf("size = %v, want 8", found[internal.MessagingMessageBodySize])
+	}
+}
+
+func TestBodyCaptureBinary(t *testing.T) {
+	body := []byte{0x00, 0xff, 0x10}
+	found, ok := bodyAttributes(t, &BodyCaptureConfig{}, "application/octet-stream", body)
+	if !ok {
+		t.Fatal("attributes() should capture binary bodies")
+	}
+
+	if found[internal.MessagingMessageBody] != base64.StdEncoding.EncodeToString(body) {
+		t.Errorf("body = %v, want base64 encoded", found[internal.MessagingMessageBody])
+	}
+	if found[internal.MessagingMessageBodyEncoding] != internal.BodyEncodingBase64 {
+		t.Errorf("encoding = %v, want %v", found[internal.MessagingMessageBodyEncoding], internal.BodyEncodingBase64)
+	}
+}
+
+func TestBodyCaptureTruncates(t *testing.T) {
+	body := append(bytes.Repeat([]byte("a"), 4), []byte("é")...)
+	found, _ := bodyAttributes(t, &BodyCaptureConfig{MaxSize: 5}, "text/plain", body)
+
+	if found[internal.MessagingMessageBody] != "aaaa" {
+		t.Errorf("body = %q, want partial rune trimmed", found[internal.MessagingMessageBody])
+	}
+	if found[internal.MessagingMessageBodyTruncated] != true {
+		t.Error("truncated should be true")
+	}
+	if found[internal.MessagingMessageBodySize] != int64(len(body)) {
+		t.Errorf("size = %v, want original size %d", found[internal.MessagingMessageBodySize], len(body))
+	}
+}
+
+func TestBodyCaptureContentTypeAllowlist(t *testing.T) {
+	config := &BodyCaptureConfig{ContentTypes: []string{"text/*", "application/json"}}
+
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"text/plain", true},
+		{"application/json", true},
+		{"APPLICATION/JSON; charset=utf-8", true},
+		{"application/octet-stream", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if _, ok := bodyAttributes(t, config, tt.contentType, []byte("x")); ok != tt.want {
+				t.Errorf("attributes() captured = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestBodyCaptureRedactor(t *testing.T) {
+	config := &BodyCaptureConfig{
+		Redactor: func(contentType string, body []byte) []byte {
+			return bytes.ReplaceAll(body, []byte("secret"), []byte("******"))
+		},
+	}
+
+	found, _ := bodyAttributes(t, config, "text/plain", []byte("token=secret"))
+	if found[internal.MessagingMessageBody] != "token=******" {
+		t.Errorf("body = %v, want redacted", found[internal.MessagingMessageBody])
+	}
+}
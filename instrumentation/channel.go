@@ -3,8 +3,11 @@ package instrumentation
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -89,17 +92,44 @@ func (c *Channel) GetConsumer() *Consumer {
 type Connection struct {
 	*amqp091.Connection
 	channelConfig ChannelConfig
+	events        *connectionEvents
+	attributes    []attribute.KeyValue
+	// endpoint and opts are kept so Redial can open an identical connection.
+	endpoint *endpoint
+	opts     []Option
 }
 
 type ConnectionConfig struct {
 	ChannelConfig ChannelConfig
+	// Meter records connection blocked, close and channel flow metrics.
+	// Defaults to the global meter provider.
+	Meter metric.Meter
+	// Logger receives blocked, flow and close notifications. Logging is
+	// disabled when nil.
+	Logger *slog.Logger
+	// RecordUser adds the user of the dial URL to spans as
+	// messaging.rabbitmq.user. Off by default.
+	RecordUser bool
 }
 
 func NewConnection(conn *amqp091.Connection, config ConnectionConfig) *Connection {
-	return &Connection{
+	return newConnection(nil, conn, config)
+}
+
+func newConnection(uri *amqp091.URI, conn *amqp091.Connection, config ConnectionConfig) *Connection {
+	c := &Connection{
 		Connection:    conn,
 		channelConfig: config.ChannelConfig,
+		events:        newConnectionEvents(config.Meter, config.Logger),
+		attributes:    connectionAttributes(uri, conn, config.RecordUser),
 	}
+	if conn != nil {
+		go c.events.watch(
+			conn.NotifyBlocked(make(chan amqp091.Blocking, 1)),
+			conn.NotifyClose(make(chan *amqp091.Error, 1)),
+		)
+	}
+	return c
 }
 
 func NewDefaultConnection(conn *amqp091.Connection) *Connection {
@@ -107,11 +137,7 @@ func NewDefaultConnection(conn *amqp091.Connection) *Connection {
 }
 
 func (c *Connection) ChannelWithTracing() (*Channel, error) {
-	ch, err := c.Connection.Channel()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create channel: %w", err)
-	}
-	return NewChannel(ch, c.channelConfig), nil
+	return c.ChannelWithTracingAndConfig(c.channelConfig)
 }
 
 func (c *Connection) ChannelWithTracingAndConfig(config ChannelConfig) (*Channel, error) {
@@ -119,37 +145,35 @@ func (c *Connection) ChannelWithTracingAndConfig(config ChannelConfig) (*Channel
 	if err != nil {
 		return nil, fmt.Errorf("failed to create channel: %w", err)
 	}
-	return NewChannel(ch, config), nil
+	return c.newChannel(ch, config), nil
+}
+
+// newChannel ties a channel and its publisher to the connection state.
+func (c *Connection) newChannel(ch *amqp091.Channel, config ChannelConfig) *Channel {
+	channel := NewChannel(ch, config)
+	channel.publisher.conn = c
+	channel.consumer.conn = c
+	if ch != nil {
+		go c.events.watchChannel(
+			ch.NotifyFlow(make(chan bool, 1)),
+			ch.NotifyClose(make(chan *amqp091.Error, 1)),
+		)
+	}
+	return channel
 }
 
 func Dial(url string) (*Connection, error) {
-	conn, err := amqp091.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-	return NewDefaultConnection(conn), nil
+	return Open(context.Background(), url)
 }
 
 func DialWithConfig(url string, config ConnectionConfig) (*Connection, error) {
-	conn, err := amqp091.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-	return NewConnection(conn, config), nil
+	return Open(context.Background(), url, WithConnectionConfig(config))
 }
 
 func DialConfig(url string, amqpConfig amqp091.Config) (*Connection, error) {
-	conn, err := amqp091.DialConfig(url, amqpConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ with config: %w", err)
-	}
-	return NewDefaultConnection(conn), nil
+	return Open(context.Background(), url, WithAMQPConfig(amqpConfig))
 }
 
 func DialConfigWithConfig(url string, amqpConfig amqp091.Config, config ConnectionConfig) (*Connection, error) {
-	conn, err := amqp091.DialConfig(url, amqpConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ with config: %w", err)
-	}
-	return NewConnection(conn, config), nil
+	return Open(context.Background(), url, WithAMQPConfig(amqpConfig), WithConnectionConfig(config))
 }
@@ -0,0 +1,229 @@
+package instrumentation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultCompressionThreshold = 1024
+	DefaultMaxDecompressedSize  = 64 << 20
+)
+
+var ErrDecompressedTooLarge = errors.New("decompressed message exceeds the size limit")
+
+// Compressor is a body compression codec identified by its ContentEncoding
+// value. Codecs such as snappy can be plugged in by wrapping their stream
+// readers and writers.
+type Compressor interface {
+	ContentEncoding() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	Gzip    Compressor = gzipCompressor{level: gzip.DefaultCompression}
+	Deflate Compressor = deflateCompressor{level: zlib.DefaultCompression}
+	Zstd    Compressor = zstdCompressor{level: zstd.SpeedDefault}
+)
+
+// NewGzipCompressor returns a gzip Compressor with a compress/gzip level.
+func NewGzipCompressor(level int) Compressor {
+	return gzipCompressor{level: level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) ContentEncoding() string { return "gzip" }
+
+func (c gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// NewZstdCompressor returns a zstd Compressor with a zstd level from 1 to 22.
+func NewZstdCompressor(level int) Compressor {
+	return zstdCompressor{level: zstd.EncoderLevelFromZstd(level)}
+}
+
+// zstdCompressor uses a single goroutine per stream, since bodies are
+// compressed one message at a time.
+type zstdCompressor struct {
+	level zstd.EncoderLevel
+}
+
+func (zstdCompressor) ContentEncoding() string { return "zstd" }
+
+func (c zstdCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(c.level), zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
+
+// deflateCompressor uses the zlib format, as HTTP does for "deflate".
+type deflateCompressor struct {
+	level int
+}
+
+func (deflateCompressor) ContentEncoding() string { return "deflate" }
+
+func (c deflateCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, c.level)
+}
+
+func (deflateCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+// CompressionConfig compresses published bodies and sets ContentEncoding.
+// Messages that already have a ContentEncoding are left untouched.
+type CompressionConfig struct {
+	// Compressor defaults to Gzip.
+	Compressor Compressor
+	// Threshold is the body size from which messages are compressed.
+	// Defaults to DefaultCompressionThreshold.
+	Threshold int
+}
+
+// DecompressionConfig controls how consumers decompress bodies whose
+// ContentEncoding matches a known Compressor before the handler runs.
+// Gzip, Deflate and Zstd are always known.
+type DecompressionConfig struct {
+	Disabled    bool
+	Compressors []Compressor
+	// MaxSize bounds decompressed bodies. Larger messages are
+	// dead-lettered. Defaults to DefaultMaxDecompressedSize.
+	MaxSize int64
+}
+
+func (p *Publisher) compress(next PublishFunc) PublishFunc {
+	config := p.config.Compression
+	if config == nil {
+		return next
+	}
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		if msg.ContentEncoding != "" || len(msg.Body) < config.Threshold {
+			return next(ctx, exchange, routingKey, msg)
+		}
+
+		compressed, err := compressBody(config.Compressor, msg.Body)
+		if err != nil {
+			return fmt.Errorf("failed to compress message: %w", err)
+		}
+		trace.SpanFromContext(ctx).SetAttributes(compressionAttributes(config.Compressor, len(msg.Body), len(compressed))...)
+
+		compressedMsg := *msg
+		compressedMsg.Body = compressed
+		compressedMsg.ContentEncoding = config.Compressor.ContentEncoding()
+		return next(ctx, exchange, routingKey, &compressedMsg)
+	}
+}
+
+func compressBody(compressor Compressor, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := compressor.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *Consumer) decompressor(delivery *amqp091.Delivery) Compressor {
+	if c.config.Decompression.Disabled || delivery.ContentEncoding == "" || isCiphertext(delivery) {
+		return nil
+	}
+	for _, compressor := range c.config.Decompression.Compressors {
+		if compressor.ContentEncoding() == delivery.ContentEncoding {
+			return compressor
+		}
+	}
+	switch delivery.ContentEncoding {
+	case Gzip.ContentEncoding():
+		return Gzip
+	case Deflate.ContentEncoding():
+		return Deflate
+	case Zstd.ContentEncoding():
+		return Zstd
+	}
+	return nil
+}
+
+// decompress replaces compressed bodies before next runs. Bodies that cannot
+// be decompressed are dead-lettered, since redelivery would not help.
+func (c *Consumer) decompress(next MessageHandler) MessageHandler {
+	return func(ctx context.Context, delivery amqp091.Delivery) error {
+		compressor := c.decompressor(&delivery)
+		if compressor == nil {
+			return next(ctx, delivery)
+		}
+
+		span := trace.SpanFromContext(ctx)
+		body, err := decompressBody(compressor, delivery.Body, c.config.Decompression.MaxSize)
+		if err != nil {
+			span.SetAttributes(attribute.Bool(internal.MessagingOrbDecodeFailed, true))
+			return DeadLetter(fmt.Errorf("failed to decompress %s message: %w", compressor.ContentEncoding(), err))
+		}
+		span.SetAttributes(compressionAttributes(compressor, len(body), len(delivery.Body))...)
+
+		delivery.Body = body
+		delivery.ContentEncoding = ""
+		if c.config.BodyCapture != nil && !isEncrypted(&delivery) {
+			c.config.BodyCapture.record(span, delivery.ContentType, delivery.Body)
+		}
+		return next(ctx, delivery)
+	}
+}
+
+func decompressBody(compressor Compressor, body []byte, maxSize int64) ([]byte, error) {
+	r, err := compressor.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decompressed)) > maxSize {
+		return nil, ErrDecompressedTooLarge
+	}
+	return decompressed, nil
+}
+
+func compressionAttributes(compressor Compressor, originalSize, compressedSize int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(internal.MessagingOrbCompressionEncoding, compressor.ContentEncoding()),
+		attribute.Int(internal.MessagingOrbCompressionOriginalSize, originalSize),
+		attribute.Int(internal.MessagingOrbCompressionCompressedSize, compressedSize),
+	}
+}
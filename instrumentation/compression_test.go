@@ -0,0 +1,135 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestPublisherCompression(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	publisher := NewPublisher(PublisherConfig{
+		Tracer:      tracer,
+		Compression: &CompressionConfig{Threshold: 100},
+	})
+
+	var sent []sentMessage
+	large := []byte(strings.Repeat(`{"item":"widget"},`, 100))
+	_ = publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{Body: large}, recordingSend(&sent))
+	_ = publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{Body: []byte("small")}, recordingSend(&sent))
+
+	if sent[0].msg.ContentEncoding != "gzip" || len(sent[0].msg.Body) >= len(large) {
+		t.Errorf("large message encoding = %q, size = %d", sent[0].msg.ContentEncoding, len(sent[0].msg.Body))
+	}
+	if sent[1].msg.ContentEncoding != "" || string(sent[1].msg.Body) != "small" {
+		t.Errorf("small message should not be compressed")
+	}
+
+	attrs := map[string]int64{}
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInt64()
+	}
+	if attrs[internal.MessagingOrbCompressionOriginalSize] != int64(len(large)) ||
+		attrs[internal.MessagingOrbCompressionCompressedSize] != int64(len(sent[0].msg.Body)) {
+		t.Errorf("compression attributes = %v", attrs)
+	}
+}
+
+func TestConsumerDecompression(t *testing.T) {
+	body := []byte(strings.Repeat("hello ", 50))
+	for _, compressor := range []Compressor{Gzip, Deflate, Zstd, NewZstdCompressor(19)} {
+		compressed, err := compressBody(compressor, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		consumer := NewConsumer(ConsumerConfig{})
+		var got amqp091.Delivery
+		_ = consumer.ProcessDelivery(context.Background(), "q",
+			amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, ContentEncoding: compressor.ContentEncoding(), Body: compressed},
+			func(ctx context.Context, delivery amqp091.Delivery) error {
+				got = delivery
+				return nil
+			})
+
+		if !bytes.Equal(got.Body, body) || got.ContentEncoding != "" {
+			t.Errorf("%s: handler got encoding %q, body %q", compressor.ContentEncoding(), got.ContentEncoding, got.Body)
+		}
+	}
+}
+
+func TestConsumerDecompressionLimit(t *testing.T) {
+	compressed, _ := compressBody(Gzip, make([]byte, 1000))
+	consumer := NewConsumer(ConsumerConfig{Decompression: DecompressionConfig{MaxSize: 100}})
+
+	ack := &fakeAcknowledger{}
+	called := false
+	_ = consumer.ProcessDelivery(context.Background(), "q",
+		amqp091.Delivery{Acknowledger: ack, ContentEncoding: "gzip", Body: compressed},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			called = true
+			return nil
+		})
+
+	if called {
+		t.Error("handler should not run for oversized messages")
+	}
+	if ack.nacked != 1 || ack.requeue {
+		t.Errorf("oversized message should be dead-lettered, nacked = %d, requeue = %v", ack.nacked, ack.requeue)
+	}
+}
+
+func TestConsumerDeadLettersCorruptBodies(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	consumer := NewConsumer(ConsumerConfig{Tracer: tracer})
+
+	ack := &fakeAcknowledger{}
+	called := false
+	_ = consumer.ProcessDelivery(context.Background(), "q",
+		amqp091.Delivery{Acknowledger: ack, ContentEncoding: "gzip", Body: []byte("not gzip")},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			called = true
+			return nil
+		})
+
+	if called {
+		t.Error("handler should not run for bodies that fail to decompress")
+	}
+	if ack.nacked != 1 || ack.requeue {
+		t.Errorf("corrupt message should be dead-lettered, nacked = %d, requeue = %v", ack.nacked, ack.requeue)
+	}
+	if !spanBool(recorder.Ended()[0], internal.MessagingOrbDecodeFailed) {
+		t.Error("span should record the decode failure")
+	}
+
+	disabled := NewConsumer(ConsumerConfig{Decompression: DecompressionConfig{Disabled: true}})
+	var got amqp091.Delivery
+	_ = disabled.ProcessDelivery(context.Background(), "q",
+		amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, ContentEncoding: "gzip", Body: []byte("not gzip")},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			got = delivery
+			return nil
+		})
+	if got.ContentEncoding != "gzip" || string(got.Body) != "not gzip" {
+		t.Errorf("disabled decompression should pass bodies through, got %q %q", got.ContentEncoding, got.Body)
+	}
+}
+
+func TestConsumerLeavesUnknownEncodings(t *testing.T) {
+	consumer := NewConsumer(ConsumerConfig{})
+	var got amqp091.Delivery
+	_ = consumer.ProcessDelivery(context.Background(), "q",
+		amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, ContentEncoding: "br", Body: []byte("raw")},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			got = delivery
+			return nil
+		})
+
+	if got.ContentEncoding != "br" || string(got.Body) != "raw" {
+		t.Errorf("unknown encodings should pass through, got %q %q", got.ContentEncoding, got.Body)
+	}
+}
@@ -3,6 +3,7 @@ package instrumentation
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/startower-observability/orb/internal"
@@ -15,10 +16,29 @@ type ConsumerConfig struct {
 	Propagator        *Propagator
 	SpanNameFormatter func(queueName string, delivery *amqp091.Delivery) string
 	AttributeEnricher func(ctx context.Context, queueName string, delivery *amqp091.Delivery) []trace.SpanStartOption
+	BodyCapture       *BodyCaptureConfig
+	HeaderCapture     *HeaderCaptureConfig
+	Filter            ConsumeFilter
+	LatencyThreshold  time.Duration
+	OnNack            func(ctx context.Context, queueName string, delivery *amqp091.Delivery, requeue bool, err error)
+	Middlewares       []Middleware
+	HandlerTimeout    time.Duration
+	ExpiredAction     ExpiredAction
+	// SemconvMode defaults to the mode selected by
+	// OTEL_SEMCONV_STABILITY_OPT_IN.
+	SemconvMode SemconvMode
+	// Decompression decompresses gzip and deflate bodies by default.
+	Decompression DecompressionConfig
+	// Verification checks message signatures before decryption.
+	Verification *VerificationConfig
+	// Decryption decrypts bodies before decompression.
+	Decryption *DecryptionConfig
 }
 
 type Consumer struct {
 	config ConsumerConfig
+	// conn is set for consumers of channels created from a Connection.
+	conn *Connection
 }
 
 func NewConsumer(config ConsumerConfig) *Consumer {
@@ -28,9 +48,13 @@ func NewConsumer(config ConsumerConfig) *Consumer {
 	if config.Propagator == nil {
 		config.Propagator = DefaultPropagator
 	}
+	config.SemconvMode = config.SemconvMode.Resolve()
 	if config.SpanNameFormatter == nil {
 		config.SpanNameFormatter = defaultConsumeSpanName
 	}
+	if config.Decompression.MaxSize <= 0 {
+		config.Decompression.MaxSize = DefaultMaxDecompressedSize
+	}
 
 	return &Consumer{
 		config: config,
@@ -60,7 +84,7 @@ func (c *Consumer) ConsumeWithHandler(
 
 	go func() {
 		for delivery := range deliveries {
-			c.processDelivery(ctx, queueName, delivery, handler, autoAck)
+			c.processDelivery(ctx, queueName, delivery, handler, autoAck, true)
 		}
 	}()
 
@@ -73,48 +97,55 @@ func (c *Consumer) ProcessDelivery(
 	delivery amqp091.Delivery,
 	handler MessageHandler,
 ) error {
-	c.processDelivery(ctx, queueName, delivery, handler, false)
+	c.processDelivery(ctx, queueName, delivery, handler, false, true)
 	return nil
 }
 
+// processDelivery handles and settles delivery, returning the handler error.
+// Failed deliveries are requeued when requeue is set and the error is not a
+// dead-letter error.
 func (c *Consumer) processDelivery(
 	parentCtx context.Context,
 	queueName string,
 	delivery amqp091.Delivery,
 	handler MessageHandler,
-	autoAck bool,
-) {
-	ctx := c.config.Propagator.ExtractFromDelivery(parentCtx, &delivery)
-
-	spanName := c.config.SpanNameFormatter(queueName, &delivery)
+	autoAck, requeue bool,
+) error {
+	start := time.Now()
 
-	spanOpts := []trace.SpanStartOption{
-		trace.WithSpanKind(trace.SpanKindConsumer),
+	mode := FilterRecord
+	if c.config.Filter != nil {
+		mode = c.config.Filter(queueName, &delivery)
 	}
 
-	attrs := internal.GetConsumeAttributes(queueName, &delivery)
-	for _, attr := range attrs {
-		spanOpts = append(spanOpts, trace.WithAttributes(attr))
+	var ctx context.Context
+	span := nonRecordingSpan()
+	if mode == FilterRecord {
+		ctx, span = c.startSpan(parentCtx, queueName, &delivery)
+		defer span.End()
+	} else {
+		ctx = c.config.Propagator.ExtractFromDelivery(parentCtx, &delivery)
 	}
-
-	if c.config.AttributeEnricher != nil {
-		customOpts := c.config.AttributeEnricher(ctx, queueName, &delivery)
-		spanOpts = append(spanOpts, customOpts...)
-	}
-
-	ctx, span := c.config.Tracer.Start(ctx, spanName, spanOpts...)
-	defer span.End()
+	ctx = ContextWithDeliveryInfo(ctx, newDeliveryInfo(queueName, &delivery))
 
 	var err error
 	if handler != nil {
-		err = handler(ctx, delivery)
+		if len(c.config.Middlewares) > 0 {
+			handler = Chain(c.config.Middlewares...)(handler)
+		}
+		handler = c.inbound(handler)
+		err = c.runHandler(ctx, span, delivery, handler)
 	}
 
 	if !autoAck {
 		if err != nil {
-			if nackErr := delivery.Nack(false, true); nackErr != nil {
+			requeue := requeue && !IsDeadLetter(err)
+			if nackErr := delivery.Nack(false, requeue); nackErr != nil {
 				span.RecordError(fmt.Errorf("failed to nack message: %w", nackErr))
 			}
+			if c.config.OnNack != nil {
+				c.config.OnNack(ctx, queueName, &delivery, requeue, err)
+			}
 		} else {
 			if ackErr := delivery.Ack(false); ackErr != nil {
 				span.RecordError(fmt.Errorf("failed to ack message: %w", ackErr))
@@ -124,12 +155,33 @@ func (c *Consumer) processDelivery(
 	}
 
 	internal.SafeSetSpanStatus(span, err)
+
+	if elapsed := time.Since(start); shouldRecordDeferred(mode, err, elapsed, c.config.LatencyThreshold) {
+		_, deferred := c.startSpan(parentCtx, queueName, &delivery,
+			trace.WithTimestamp(start), deferredSpanAttributes(elapsed, c.config.LatencyThreshold))
+		internal.SafeSetSpanStatus(deferred, err)
+		deferred.End()
+	}
+	return err
 }
 
 func (c *Consumer) WrapDelivery(
 	ctx context.Context,
 	queueName string,
 	delivery *amqp091.Delivery,
+) (context.Context, trace.Span) {
+	ctx = ContextWithDeliveryInfo(ctx, newDeliveryInfo(queueName, delivery))
+	if c.config.Filter != nil && c.config.Filter(queueName, delivery) == FilterSkip {
+		return c.config.Propagator.ExtractFromDelivery(ctx, delivery), nonRecordingSpan()
+	}
+	return c.startSpan(ctx, queueName, delivery)
+}
+
+func (c *Consumer) startSpan(
+	ctx context.Context,
+	queueName string,
+	delivery *amqp091.Delivery,
+	opts ...trace.SpanStartOption,
 ) (context.Context, trace.Span) {
 	ctx = c.config.Propagator.ExtractFromDelivery(ctx, delivery)
 
@@ -139,7 +191,12 @@ func (c *Consumer) WrapDelivery(
 		trace.WithSpanKind(trace.SpanKindConsumer),
 	}
 
-	attrs := internal.GetConsumeAttributes(queueName, delivery)
+	attrs := internal.ConvertAttributes(c.config.SemconvMode, internal.GetConsumeAttributes(queueName, delivery))
+	if c.config.HeaderCapture != nil {
+		attrs = append(attrs, c.config.HeaderCapture.attributes(delivery.Headers)...)
+	}
+	attrs = append(attrs, c.config.Propagator.baggageAttributes(ctx)...)
+	attrs = append(attrs, c.conn.spanAttributes()...)
 	for _, attr := range attrs {
 		spanOpts = append(spanOpts, trace.WithAttributes(attr))
 	}
@@ -148,8 +205,28 @@ func (c *Consumer) WrapDelivery(
 		customOpts := c.config.AttributeEnricher(ctx, queueName, delivery)
 		spanOpts = append(spanOpts, customOpts...)
 	}
+	spanOpts = append(spanOpts, spanStartOptionsFromContext(ctx)...)
+	spanOpts = append(spanOpts, opts...)
+
+	ctx, span := c.config.Tracer.Start(ctx, spanName, spanOpts...)
+	ctx = withoutSpanStartOptions(ctx)
+
+	// Encoded bodies are captured once they have been decoded.
+	if c.config.BodyCapture != nil && !c.decodesBody(delivery) {
+		c.config.BodyCapture.record(span, delivery.ContentType, delivery.Body)
+	}
+
+	return ctx, span
+}
+
+// inbound wraps handler with the body transformations applied before the
+// middlewares run.
+func (c *Consumer) inbound(handler MessageHandler) MessageHandler {
+	return c.verify(c.decrypt(c.decompress(handler)))
+}
 
-	return c.config.Tracer.Start(ctx, spanName, spanOpts...)
+func (c *Consumer) decodesBody(delivery *amqp091.Delivery) bool {
+	return isEncrypted(delivery) || c.decompressor(delivery) != nil
 }
 
 func defaultConsumeSpanName(queueName string, delivery *amqp091.Delivery) string {
@@ -0,0 +1,80 @@
+package instrumentation
+
+import (
+	"context"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DeliveryInfo describes the delivery being processed. The consumer stores it
+// in the context passed to a MessageHandler.
+type DeliveryInfo struct {
+	Queue       string
+	Exchange    string
+	RoutingKey  string
+	MessageID   string
+	DeliveryTag uint64
+	Redelivered bool
+}
+
+type deliveryInfoKey struct{}
+
+func ContextWithDeliveryInfo(ctx context.Context, info DeliveryInfo) context.Context {
+	return context.WithValue(ctx, deliveryInfoKey{}, info)
+}
+
+func DeliveryInfoFromContext(ctx context.Context) (DeliveryInfo, bool) {
+	info, ok := ctx.Value(deliveryInfoKey{}).(DeliveryInfo)
+	return info, ok
+}
+
+func newDeliveryInfo(queueName string, delivery *amqp091.Delivery) DeliveryInfo {
+	return DeliveryInfo{
+		Queue:       queueName,
+		Exchange:    delivery.Exchange,
+		RoutingKey:  delivery.RoutingKey,
+		MessageID:   delivery.MessageId,
+		DeliveryTag: delivery.DeliveryTag,
+		Redelivered: delivery.Redelivered,
+	}
+}
+
+type spanStartOptionsKey struct{}
+
+// ContextWithSpanStartOptions attaches options, such as attributes or links,
+// to the next publish or consume span started with ctx.
+func ContextWithSpanStartOptions(ctx context.Context, opts ...trace.SpanStartOption) context.Context {
+	existing := spanStartOptionsFromContext(ctx)
+	merged := make([]trace.SpanStartOption, 0, len(existing)+len(opts))
+	merged = append(merged, existing...)
+	merged = append(merged, opts...)
+	return context.WithValue(ctx, spanStartOptionsKey{}, merged)
+}
+
+func spanStartOptionsFromContext(ctx context.Context) []trace.SpanStartOption {
+	opts, _ := ctx.Value(spanStartOptionsKey{}).([]trace.SpanStartOption)
+	return opts
+}
+
+// withoutSpanStartOptions keeps options meant for one span from leaking into
+// spans started further down the call chain.
+func withoutSpanStartOptions(ctx context.Context) context.Context {
+	if ctx.Value(spanStartOptionsKey{}) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanStartOptionsKey{}, nil)
+}
+
+type semconvModeKey struct{}
+
+// contextWithSemconvMode lets interceptors record span attributes in the
+// conventions of the publisher running them.
+func contextWithSemconvMode(ctx context.Context, mode SemconvMode) context.Context {
+	return context.WithValue(ctx, semconvModeKey{}, mode)
+}
+
+func semconvModeFromContext(ctx context.Context) SemconvMode {
+	mode, _ := ctx.Value(semconvModeKey{}).(SemconvMode)
+	return mode.Resolve()
+}
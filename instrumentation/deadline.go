@@ -0,0 +1,92 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrMessageExpired = errors.New("message deadline exceeded")
+
+// ExpiredAction decides what happens to a message whose publisher deadline
+// has passed, either before or while it is handled.
+type ExpiredAction int
+
+const (
+	// ExpiredDeadLetter nacks the message without requeueing it.
+	ExpiredDeadLetter ExpiredAction = iota
+	// ExpiredDrop acknowledges and discards the message.
+	ExpiredDrop
+)
+
+func (a ExpiredAction) String() string {
+	switch a {
+	case ExpiredDrop:
+		return "drop"
+	default:
+		return "dead_letter"
+	}
+}
+
+func injectDeadline(ctx context.Context, headers amqp091.Table) {
+	if deadline, ok := ctx.Deadline(); ok {
+		headers[internal.DeadlineHeader] = deadline.UnixMilli()
+	}
+}
+
+// runHandler invokes handler with a context bounded by the configured
+// HandlerTimeout and the publisher deadline carried by the message.
+func (c *Consumer) runHandler(
+	ctx context.Context,
+	span trace.Span,
+	delivery amqp091.Delivery,
+	handler MessageHandler,
+) error {
+	deadline, hasDeadline := internal.MessageDeadline(delivery.Headers)
+	if hasDeadline && !time.Now().Before(deadline) {
+		return c.expire(span, ErrMessageExpired)
+	}
+
+	if hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	if c.config.HandlerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.HandlerTimeout)
+		defer cancel()
+	}
+
+	err := handler(ctx, delivery)
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		span.SetAttributes(attribute.Bool(internal.MessagingOrbDeadlineExceeded, true))
+		if err != nil && hasDeadline && !time.Now().Before(deadline) {
+			return c.expire(span, fmt.Errorf("%w: %w", ErrMessageExpired, err))
+		}
+	}
+
+	return err
+}
+
+func (c *Consumer) expire(span trace.Span, cause error) error {
+	span.SetAttributes(
+		attribute.Bool(internal.MessagingOrbDeadlineExceeded, true),
+		attribute.String(internal.MessagingOrbExpiredAction, c.config.ExpiredAction.String()),
+	)
+
+	if c.config.ExpiredAction == ExpiredDrop {
+		span.AddEvent("message.expired", trace.WithAttributes(
+			attribute.String("exception.message", cause.Error()),
+		))
+		return nil
+	}
+	return DeadLetter(cause)
+}
@@ -0,0 +1,114 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestPublisherPropagatesDeadline(t *testing.T) {
+	publisher := NewPublisher(PublisherConfig{PropagateDeadline: true})
+
+	deadline := time.Now().Add(time.Minute)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	var sent []sentMessage
+	if err := publisher.publish(ctx, "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	if got := sent[0].msg.Headers[internal.DeadlineHeader]; got != deadline.UnixMilli() {
+		t.Errorf("deadline header = %v, want %v", got, deadline.UnixMilli())
+	}
+}
+
+func TestConsumerExpiredMessages(t *testing.T) {
+	expired := amqp091.Table{internal.DeadlineHeader: time.Now().Add(-time.Second).UnixMilli()}
+
+	tests := []struct {
+		name        string
+		action      ExpiredAction
+		wantAcked   int
+		wantNacked  int
+		wantRequeue bool
+	}{
+		{"dead letter", ExpiredDeadLetter, 0, 1, false},
+		{"drop", ExpiredDrop, 1, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := newTestTracer()
+			consumer := NewConsumer(ConsumerConfig{Tracer: tracer, ExpiredAction: tt.action})
+
+			called := false
+			ack := &fakeAcknowledger{}
+			_ = consumer.ProcessDelivery(context.Background(), "orders",
+				amqp091.Delivery{Acknowledger: ack, Headers: expired},
+				func(ctx context.Context, delivery amqp091.Delivery) error {
+					called = true
+					return nil
+				})
+
+			if called {
+				t.Error("expired messages should not reach the handler")
+			}
+			if ack.acked != tt.wantAcked || ack.nacked != tt.wantNacked || ack.requeue != tt.wantRequeue {
+				t.Errorf("ack = %+v, want acked %d nacked %d", ack, tt.wantAcked, tt.wantNacked)
+			}
+
+			attrs := make(map[string]interface{})
+			for _, attr := range recorder.Ended()[0].Attributes() {
+				attrs[string(attr.Key)] = attr.Value.AsInterface()
+			}
+			if attrs[internal.MessagingOrbDeadlineExceeded] != true {
+				t.Error("span should record the exceeded deadline")
+			}
+			if attrs[internal.MessagingOrbExpiredAction] != tt.action.String() {
+				t.Errorf("expired action = %v, want %v", attrs[internal.MessagingOrbExpiredAction], tt.action)
+			}
+		})
+	}
+}
+
+func TestConsumerHonoursMessageDeadline(t *testing.T) {
+	consumer := NewDefaultConsumer()
+	deadline := time.Now().Add(20 * time.Millisecond)
+
+	ack := &fakeAcknowledger{}
+	var handlerErr error
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: ack, Headers: amqp091.Table{internal.DeadlineHeader: deadline.UnixMilli()}},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			<-ctx.Done()
+			handlerErr = ctx.Err()
+			return handlerErr
+		})
+
+	if !errors.Is(handlerErr, context.DeadlineExceeded) {
+		t.Errorf("handler context error = %v, want %v", handlerErr, context.DeadlineExceeded)
+	}
+	if ack.nacked != 1 || ack.requeue {
+		t.Errorf("ack = %+v, want dead-lettered after the deadline passed", ack)
+	}
+}
+
+func TestConsumerHandlerTimeout(t *testing.T) {
+	consumer := NewConsumer(ConsumerConfig{HandlerTimeout: 10 * time.Millisecond})
+
+	ack := &fakeAcknowledger{}
+	_ = consumer.ProcessDelivery(context.Background(), "orders", amqp091.Delivery{Acknowledger: ack},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+	if ack.nacked != 1 || !ack.requeue {
+		t.Errorf("ack = %+v, want requeued after a handler timeout", ack)
+	}
+}
@@ -0,0 +1,179 @@
+package instrumentation
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minDedupCompaction is the fewest appended lines that trigger a compaction
+// of stores without a capacity.
+const minDedupCompaction = 1024
+
+// FileDedupStore is a MemoryDedupStore persisted to an append-only file, so
+// handled keys survive restarts. The file is compacted when it is opened and
+// whenever the lines appended since the last compaction reach the capacity,
+// keeping it at most about twice the size of the live keys.
+type FileDedupStore struct {
+	*MemoryDedupStore
+
+	path     string
+	mu       sync.Mutex
+	file     *os.File
+	appended int
+}
+
+// NewFileDedupStore loads the keys recorded at path, dropping expired ones,
+// and appends new keys to it. Capacity and ttl behave as for
+// NewMemoryDedupStore.
+func NewFileDedupStore(path string, capacity int, ttl time.Duration) (*FileDedupStore, error) {
+	s := &FileDedupStore{
+		MemoryDedupStore: NewMemoryDedupStore(capacity, ttl),
+		path:             path,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileDedupStore) Add(ctx context.Context, key string) error {
+	var expires time.Time
+	if s.ttl > 0 {
+		expires = s.now().Add(s.ttl)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := s.file.WriteString(formatDedupEntry(key, expires)); err != nil {
+		return fmt.Errorf("failed to write deduplication key: %w", err)
+	}
+	s.add(key, expires)
+
+	s.appended++
+	threshold := s.capacity
+	if threshold <= 0 {
+		threshold = max(s.Len(), minDedupCompaction)
+	}
+	if s.appended >= threshold {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileDedupStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open deduplication store: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, expires, ok := parseDedupEntry(scanner.Text())
+		if !ok {
+			// A torn final line from a crash is skipped.
+			continue
+		}
+		s.add(key, expires)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read deduplication store: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the file with the live keys and reopens it for appending.
+// s.mu must be held, or s not yet shared.
+func (s *FileDedupStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to compact deduplication store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, entry := range s.live() {
+		if _, err := w.WriteString(formatDedupEntry(entry.key, entry.expires)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact deduplication store: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact deduplication store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact deduplication store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to compact deduplication store: %w", err)
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.appended = 0
+	s.file, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open deduplication store: %w", err)
+	}
+	return nil
+}
+
+// Entries are stored one per line as "<expiry unix ms> <quoted key>", with
+// an expiry of 0 for keys that never expire.
+func formatDedupEntry(key string, expires time.Time) string {
+	var ms int64
+	if !expires.IsZero() {
+		ms = expires.UnixMilli()
+	}
+	return strconv.FormatInt(ms, 10) + " " + strconv.Quote(key) + "\n"
+}
+
+func parseDedupEntry(line string) (string, time.Time, bool) {
+	msStr, quoted, ok := strings.Cut(line, " ")
+	if !ok {
+		return "", time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(msStr, 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	key, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+
+	var expires time.Time
+	if ms != 0 {
+		expires = time.UnixMilli(ms)
+	}
+	return key, expires, true
+}
@@ -0,0 +1,258 @@
+package instrumentation
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrNotEncrypted = errors.New("message is not encrypted")
+	ErrUnknownKey   = errors.New("unknown encryption key")
+)
+
+const EncryptionAlgorithmAES256GCM = "AES-256-GCM"
+
+// KeyProvider wraps and unwraps the per-message data keys with key
+// encryption keys, typically held by a KMS.
+type KeyProvider interface {
+	// WrapKey encrypts dataKey with the current key and returns its ID.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// EncryptionConfig encrypts published bodies with a fresh AES-256-GCM data
+// key per message. The wrapped data key, its key ID and the nonce are sent in
+// headers. Body capture is disabled for encrypting publishers.
+type EncryptionConfig struct {
+	KeyProvider KeyProvider
+}
+
+// DecryptionConfig decrypts bodies before decompression and the handler.
+// Decrypted bodies are never captured.
+type DecryptionConfig struct {
+	KeyProvider KeyProvider
+	// RequireEncryption dead-letters messages that are not encrypted.
+	RequireEncryption bool
+}
+
+func (p *Publisher) encrypt(next PublishFunc) PublishFunc {
+	config := p.config.Encryption
+	if config == nil {
+		return next
+	}
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			return fmt.Errorf("failed to generate data key: %w", err)
+		}
+		keyID, wrapped, err := config.KeyProvider.WrapKey(ctx, dataKey)
+		if err != nil {
+			return fmt.Errorf("failed to wrap data key: %w", err)
+		}
+		nonce, ciphertext, err := sealAESGCM(dataKey, msg.Body, []byte(keyID))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt message: %w", err)
+		}
+
+		encrypted := *msg
+		encrypted.Body = ciphertext
+		encrypted.Headers = maps.Clone(msg.Headers)
+		if encrypted.Headers == nil {
+			encrypted.Headers = make(amqp091.Table)
+		}
+		encrypted.Headers[internal.EncryptionAlgorithmHeader] = EncryptionAlgorithmAES256GCM
+		encrypted.Headers[internal.EncryptionKeyIDHeader] = keyID
+		encrypted.Headers[internal.EncryptionDataKeyHeader] = base64.StdEncoding.EncodeToString(wrapped)
+		encrypted.Headers[internal.EncryptionNonceHeader] = base64.StdEncoding.EncodeToString(nonce)
+
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String(internal.MessagingOrbEncryptionKeyID, keyID))
+		return next(ctx, exchange, routingKey, &encrypted)
+	}
+}
+
+// isEncrypted reports whether the body is, or was before decryption,
+// encrypted.
+func isEncrypted(delivery *amqp091.Delivery) bool {
+	_, ok := delivery.Headers[internal.EncryptionKeyIDHeader]
+	return ok
+}
+
+// isCiphertext reports whether the body still has to be decrypted.
+func isCiphertext(delivery *amqp091.Delivery) bool {
+	_, ok := delivery.Headers[internal.EncryptionNonceHeader]
+	return ok
+}
+
+// decrypt replaces encrypted bodies before next runs. The key ID header is
+// kept so later stages know the body was encrypted.
+func (c *Consumer) decrypt(next MessageHandler) MessageHandler {
+	config := c.config.Decryption
+	if config == nil {
+		return next
+	}
+	return func(ctx context.Context, delivery amqp091.Delivery) error {
+		span := trace.SpanFromContext(ctx)
+		if !isEncrypted(&delivery) {
+			if config.RequireEncryption {
+				return DeadLetter(ErrNotEncrypted)
+			}
+			return next(ctx, delivery)
+		}
+
+		keyID, _ := delivery.Headers[internal.EncryptionKeyIDHeader].(string)
+		span.SetAttributes(attribute.String(internal.MessagingOrbEncryptionKeyID, keyID))
+
+		body, err := decryptDelivery(ctx, config.KeyProvider, keyID, &delivery)
+		if err != nil {
+			span.SetAttributes(attribute.Bool(internal.MessagingOrbDecodeFailed, true))
+			return DeadLetter(fmt.Errorf("failed to decrypt message: %w", err))
+		}
+		delivery.Body = body
+		delivery.Headers = maps.Clone(delivery.Headers)
+		delete(delivery.Headers, internal.EncryptionAlgorithmHeader)
+		delete(delivery.Headers, internal.EncryptionDataKeyHeader)
+		delete(delivery.Headers, internal.EncryptionNonceHeader)
+		return next(ctx, delivery)
+	}
+}
+
+func decryptDelivery(ctx context.Context, provider KeyProvider, keyID string, delivery *amqp091.Delivery) ([]byte, error) {
+	if algorithm, _ := delivery.Headers[internal.EncryptionAlgorithmHeader].(string); algorithm != EncryptionAlgorithmAES256GCM {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+	wrapped, err := headerBytes(delivery.Headers, internal.EncryptionDataKeyHeader)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := headerBytes(delivery.Headers, internal.EncryptionNonceHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := provider.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return openAESGCM(dataKey, nonce, delivery.Body, []byte(keyID))
+}
+
+func headerBytes(headers amqp091.Table, key string) ([]byte, error) {
+	encoded, ok := headers[key].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing %s header", key)
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func sealAESGCM(key, plaintext, additionalData []byte) (nonce, ciphertext []byte, err error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+func openAESGCM(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// FileKeyProvider wraps data keys with AES-256-GCM key encryption keys read
+// from a file. Each line holds a key ID and a base64 encoded 32 byte key;
+// blank lines and lines starting with # are ignored. Old keys stay in the
+// file after rotation so existing messages can still be decrypted.
+type FileKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewFileKeyProvider loads the keys at path and wraps new data keys with
+// currentKeyID.
+func NewFileKeyProvider(path, currentKeyID string) (*FileKeyProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer f.Close()
+
+	keys := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid key file line %d", line)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("invalid key %q on key file line %d: want 32 base64 encoded bytes", fields[0], line)
+		}
+		keys[fields[0]] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("%w: %q is not in the key file", ErrUnknownKey, currentKeyID)
+	}
+
+	return &FileKeyProvider{current: currentKeyID, keys: keys}, nil
+}
+
+func (p *FileKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	nonce, ciphertext, err := sealAESGCM(p.keys[p.current], dataKey, []byte(p.current))
+	if err != nil {
+		return "", nil, err
+	}
+	return p.current, append(nonce, ciphertext...), nil
+}
+
+func (p *FileKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	aead, err := newAESGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+	return openAESGCM(key, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
@@ -0,0 +1,136 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func writeKeyFile(t *testing.T, ids ...string) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("# test keys\n")
+	for i, id := range ids {
+		b.WriteString(id + " " + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{byte(i + 1)}, 32)) + "\n")
+	}
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	path := writeKeyFile(t, "2023", "2024")
+	provider, err := NewFileKeyProvider(path, "2024")
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider() error = %v", err)
+	}
+
+	tracer, recorder := newTestTracer()
+	publisher := NewPublisher(PublisherConfig{
+		Tracer:      tracer,
+		BodyCapture: &BodyCaptureConfig{},
+		Compression: &CompressionConfig{Threshold: 1},
+		Encryption:  &EncryptionConfig{KeyProvider: provider},
+	})
+
+	secret := []byte(strings.Repeat("card=4111111111111111;", 20))
+	var sent []sentMessage
+	if err := publisher.publish(context.Background(), "payments", "charge", &amqp091.Publishing{ContentType: "text/plain", Body: secret}, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	msg := sent[0].msg
+	if bytes.Contains(msg.Body, []byte("card=")) {
+		t.Fatal("published body should be encrypted")
+	}
+	if msg.Headers[internal.EncryptionKeyIDHeader] != "2024" || msg.Headers[internal.EncryptionNonceHeader] == nil {
+		t.Errorf("encryption headers = %v", msg.Headers)
+	}
+
+	// A consumer that has rotated to a newer key can still decrypt.
+	provider, _ = NewFileKeyProvider(writeKeyFile(t, "2023", "2024", "2025"), "2025")
+	consumer := NewConsumer(ConsumerConfig{
+		Tracer:      tracer,
+		BodyCapture: &BodyCaptureConfig{},
+		Decryption:  &DecryptionConfig{KeyProvider: provider},
+	})
+	var got []byte
+	delivery := amqp091.Delivery{
+		Acknowledger: &fakeAcknowledger{}, Headers: msg.Headers, ContentType: msg.ContentType,
+		ContentEncoding: msg.ContentEncoding, Body: msg.Body,
+	}
+	_ = consumer.ProcessDelivery(context.Background(), "payments", delivery, func(ctx context.Context, delivery amqp091.Delivery) error {
+		got = delivery.Body
+		return nil
+	})
+	if !bytes.Equal(got, secret) {
+		t.Errorf("handler body = %q", got)
+	}
+
+	for _, span := range recorder.Ended() {
+		for _, event := range span.Events() {
+			if event.Name == internal.MessageBodyEvent {
+				t.Errorf("span %q captured the body of an encrypted message", span.Name())
+			}
+		}
+	}
+}
+
+func TestDecryptionFailures(t *testing.T) {
+	provider, _ := NewFileKeyProvider(writeKeyFile(t, "k1"), "k1")
+	publisher := NewPublisher(PublisherConfig{Encryption: &EncryptionConfig{KeyProvider: provider}})
+	var sent []sentMessage
+	_ = publisher.publish(context.Background(), "", "q", &amqp091.Publishing{Body: []byte("secret")}, recordingSend(&sent))
+
+	process := func(config *DecryptionConfig, delivery amqp091.Delivery) (*fakeAcknowledger, bool) {
+		ack := &fakeAcknowledger{}
+		delivery.Acknowledger = ack
+		called := false
+		_ = NewConsumer(ConsumerConfig{Decryption: config}).ProcessDelivery(context.Background(), "q", delivery,
+			func(ctx context.Context, delivery amqp091.Delivery) error {
+				called = true
+				return nil
+			})
+		return ack, called
+	}
+
+	tampered := append([]byte(nil), sent[0].msg.Body...)
+	tampered[0] ^= 0xff
+	ack, called := process(&DecryptionConfig{KeyProvider: provider}, amqp091.Delivery{Headers: sent[0].msg.Headers, Body: tampered})
+	if called || ack.nacked != 1 || ack.requeue {
+		t.Errorf("tampered message: called = %v, nacked = %d, requeue = %v", called, ack.nacked, ack.requeue)
+	}
+
+	other, _ := NewFileKeyProvider(writeKeyFile(t, "k2"), "k2")
+	if _, called := process(&DecryptionConfig{KeyProvider: other}, amqp091.Delivery{Headers: sent[0].msg.Headers, Body: sent[0].msg.Body}); called {
+		t.Error("message with an unknown key should not reach the handler")
+	}
+
+	if _, called := process(&DecryptionConfig{KeyProvider: provider, RequireEncryption: true}, amqp091.Delivery{Body: []byte("plain")}); called {
+		t.Error("RequireEncryption should reject plaintext messages")
+	}
+	if _, called := process(&DecryptionConfig{KeyProvider: provider}, amqp091.Delivery{Body: []byte("plain")}); !called {
+		t.Error("plaintext messages should pass through by default")
+	}
+}
+
+func TestFileKeyProviderErrors(t *testing.T) {
+	if _, err := NewFileKeyProvider(writeKeyFile(t, "k1"), "missing"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("NewFileKeyProvider() error = %v, want %v", err, ErrUnknownKey)
+	}
+
+	path := filepath.Join(t.TempDir(), "keys")
+	_ = os.WriteFile(path, []byte("k1 c2hvcnQ=\n"), 0o600)
+	if _, err := NewFileKeyProvider(path, "k1"); err == nil {
+		t.Error("NewFileKeyProvider() should reject short keys")
+	}
+}
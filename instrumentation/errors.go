@@ -0,0 +1,32 @@
+package instrumentation
+
+import (
+	"errors"
+)
+
+type deadLetterError struct {
+	err error
+}
+
+func (e *deadLetterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *deadLetterError) Unwrap() error {
+	return e.err
+}
+
+// DeadLetter marks a handler error as permanent. The consumer nacks such
+// messages without requeueing them so the broker routes them to the queue's
+// dead letter exchange, if one is configured.
+func DeadLetter(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &deadLetterError{err: err}
+}
+
+func IsDeadLetter(err error) bool {
+	var target *deadLetterError
+	return errors.As(err, &target)
+}
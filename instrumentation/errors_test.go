@@ -0,0 +1,61 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestDeadLetter(t *testing.T) {
+	if DeadLetter(nil) != nil {
+		t.Error("DeadLetter(nil) should return nil")
+	}
+
+	cause := errors.New("invalid payload")
+	err := fmt.Errorf("decode: %w", DeadLetter(cause))
+	if !IsDeadLetter(err) {
+		t.Error("IsDeadLetter() should see through wrapping")
+	}
+	if !errors.Is(err, cause) {
+		t.Error("DeadLetter() should preserve the cause")
+	}
+	if IsDeadLetter(cause) {
+		t.Error("IsDeadLetter() should be false for plain errors")
+	}
+}
+
+func TestConsumerNackOutcome(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantRequeue bool
+	}{
+		{"transient failure requeues", errors.New("database unavailable"), true},
+		{"dead letter does not requeue", DeadLetter(errors.New("invalid payload")), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hookRequeue *bool
+			consumer := NewConsumer(ConsumerConfig{
+				OnNack: func(ctx context.Context, queueName string, delivery *amqp091.Delivery, requeue bool, err error) {
+					hookRequeue = &requeue
+				},
+			})
+
+			ack := &fakeAcknowledger{}
+			_ = consumer.ProcessDelivery(context.Background(), "orders", amqp091.Delivery{Acknowledger: ack},
+				func(ctx context.Context, delivery amqp091.Delivery) error { return tt.err })
+
+			if ack.nacked != 1 || ack.requeue != tt.wantRequeue {
+				t.Errorf("nacked = %d requeue = %v, want 1 and %v", ack.nacked, ack.requeue, tt.wantRequeue)
+			}
+			if hookRequeue == nil || *hookRequeue != tt.wantRequeue {
+				t.Errorf("OnNack requeue = %v, want %v", hookRequeue, tt.wantRequeue)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrConnectionBlocked = errors.New("connection blocked by broker")
+
+// ConnectionBlockedError is returned by publishers configured with
+// FailFastWhenBlocked while the broker blocks the connection, typically
+// because of a memory or disk alarm.
+type ConnectionBlockedError struct {
+	Reason string
+	Since  time.Time
+}
+
+func (e *ConnectionBlockedError) Error() string {
+	return fmt.Sprintf("connection blocked by broker since %s: %s", e.Since.Format(time.RFC3339), e.Reason)
+}
+
+func (e *ConnectionBlockedError) Is(target error) bool {
+	return target == ErrConnectionBlocked
+}
+
+// connectionEvents tracks broker notifications for a connection and its
+// channels.
+type connectionEvents struct {
+	logger *slog.Logger
+
+	mu            sync.RWMutex
+	blocked       bool
+	blockedReason string
+	blockedSince  time.Time
+	// unblocked is closed when the current block is lifted.
+	unblocked chan struct{}
+
+	blockedCounter    metric.Int64UpDownCounter
+	blockedDuration   metric.Float64Histogram
+	closedCounter     metric.Int64Counter
+	flowPausedCounter metric.Int64UpDownCounter
+}
+
+func newConnectionEvents(meter metric.Meter, logger *slog.Logger) *connectionEvents {
+	if meter == nil {
+		meter = otel.Meter(internal.TracerName)
+	}
+
+	e := &connectionEvents{logger: logger}
+	e.blockedCounter, _ = meter.Int64UpDownCounter(
+		"orb.connection.blocked",
+		metric.WithDescription("Number of connections currently blocked by the broker"),
+	)
+	e.blockedDuration, _ = meter.Float64Histogram(
+		"orb.connection.blocked.duration",
+		metric.WithDescription("Time connections spent blocked by the broker"),
+		metric.WithUnit("s"),
+	)
+	e.closedCounter, _ = meter.Int64Counter(
+		"orb.connection.closed",
+		metric.WithDescription("Number of connections and channels closed by the broker or the client"),
+	)
+	e.flowPausedCounter, _ = meter.Int64UpDownCounter(
+		"orb.channel.flow.paused",
+		metric.WithDescription("Number of channels currently paused by broker flow control"),
+	)
+	return e
+}
+
+func (e *connectionEvents) watch(blockings <-chan amqp091.Blocking, closes <-chan *amqp091.Error) {
+	for blockings != nil || closes != nil {
+		select {
+		case b, ok := <-blockings:
+			if !ok {
+				blockings = nil
+				continue
+			}
+			e.handleBlocked(b)
+		case err, ok := <-closes:
+			if !ok {
+				closes = nil
+				continue
+			}
+			e.handleClose("connection", err)
+		}
+	}
+	// The connection is gone, so it can no longer be blocked.
+	e.handleBlocked(amqp091.Blocking{Active: false})
+}
+
+func (e *connectionEvents) watchChannel(flows <-chan bool, closes <-chan *amqp091.Error) {
+	paused := false
+	for flows != nil || closes != nil {
+		select {
+		case active, ok := <-flows:
+			if !ok {
+				flows = nil
+				continue
+			}
+			if active == !paused {
+				continue
+			}
+			paused = !active
+			e.handleFlow(active)
+		case err, ok := <-closes:
+			if !ok {
+				closes = nil
+				continue
+			}
+			e.handleClose("channel", err)
+		}
+	}
+	if paused {
+		e.handleFlow(true)
+	}
+}
+
+func (e *connectionEvents) handleBlocked(b amqp091.Blocking) {
+	e.mu.Lock()
+	if b.Active == e.blocked {
+		e.mu.Unlock()
+		return
+	}
+	since := e.blockedSince
+	e.blocked = b.Active
+	e.blockedReason = b.Reason
+	e.blockedSince = time.Now()
+	if b.Active {
+		e.unblocked = make(chan struct{})
+	} else if e.unblocked != nil {
+		close(e.unblocked)
+		e.unblocked = nil
+	}
+	e.mu.Unlock()
+
+	ctx := context.Background()
+	if b.Active {
+		e.blockedCounter.Add(ctx, 1)
+		e.log(slog.LevelWarn, "connection blocked by broker", slog.String("reason", b.Reason))
+		return
+	}
+
+	duration := time.Since(since)
+	e.blockedCounter.Add(ctx, -1)
+	e.blockedDuration.Record(ctx, duration.Seconds())
+	e.log(slog.LevelInfo, "connection unblocked by broker", slog.Duration("duration", duration))
+}
+
+func (e *connectionEvents) handleFlow(active bool) {
+	ctx := context.Background()
+	if active {
+		e.flowPausedCounter.Add(ctx, -1)
+		e.log(slog.LevelInfo, "channel flow resumed by broker")
+		return
+	}
+	e.flowPausedCounter.Add(ctx, 1)
+	e.log(slog.LevelWarn, "channel flow paused by broker")
+}
+
+// handleClose records a close notification. A nil error means the client
+// closed the connection or channel gracefully.
+func (e *connectionEvents) handleClose(scope string, err *amqp091.Error) {
+	attrs := []attribute.KeyValue{attribute.String("scope", scope)}
+	if err == nil {
+		e.closedCounter.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+		return
+	}
+
+	attrs = append(attrs,
+		attribute.Int("error.code", err.Code),
+		attribute.Bool("error.server", err.Server),
+	)
+	e.closedCounter.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+	e.log(slog.LevelError, scope+" closed",
+		slog.Int("code", err.Code),
+		slog.String("reason", err.Reason),
+		slog.Bool("server", err.Server),
+		slog.Bool("recoverable", err.Recover),
+	)
+}
+
+func (e *connectionEvents) blockedError() *ConnectionBlockedError {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if !e.blocked {
+		return nil
+	}
+	return &ConnectionBlockedError{Reason: e.blockedReason, Since: e.blockedSince}
+}
+
+// waitUnblocked waits until the connection is not blocked.
+func (e *connectionEvents) waitUnblocked(ctx context.Context) error {
+	e.mu.RLock()
+	unblocked := e.unblocked
+	e.mu.RUnlock()
+	if unblocked == nil {
+		return nil
+	}
+	select {
+	case <-unblocked:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *connectionEvents) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	if e.logger == nil {
+		return
+	}
+	e.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// IsBlocked reports whether the broker currently blocks publishing on the
+// connection.
+func (c *Connection) IsBlocked() bool {
+	return c.events.blockedError() != nil
+}
+
+// BlockedReason returns the reason the broker gave for blocking the
+// connection, or an empty string when it is not blocked.
+func (c *Connection) BlockedReason() string {
+	if err := c.events.blockedError(); err != nil {
+		return err.Reason
+	}
+	return ""
+}
+
+// checkBlocked adds a span event while the connection is blocked and, with
+// FailFastWhenBlocked, returns the error the publish should fail with.
+func (p *Publisher) checkBlocked(ctx context.Context, span trace.Span) error {
+	if p.conn == nil {
+		return nil
+	}
+	blocked := p.conn.events.blockedError()
+	if blocked == nil {
+		return nil
+	}
+
+	span.AddEvent("connection.blocked", trace.WithAttributes(
+		attribute.String("reason", blocked.Reason),
+		attribute.Float64("blocked_for_seconds", time.Since(blocked.Since).Seconds()),
+	))
+	span.SetAttributes(attribute.Bool(internal.MessagingOrbConnectionBlocked, true))
+	if p.config.FailFastWhenBlocked && !isSpooling(ctx) {
+		return blocked
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestConnectionBlockedState(t *testing.T) {
+	var logs bytes.Buffer
+	conn := NewConnection(nil, ConnectionConfig{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+
+	blockings := make(chan amqp091.Blocking)
+	closes := make(chan *amqp091.Error)
+	done := make(chan struct{})
+	go func() {
+		conn.events.watch(blockings, closes)
+		close(done)
+	}()
+
+	blockings <- amqp091.Blocking{Active: true, Reason: "low on memory"}
+	closes <- nil // wait for the blocking notification to be handled
+	if !conn.IsBlocked() || conn.BlockedReason() != "low on memory" {
+		t.Errorf("IsBlocked() = %v, BlockedReason() = %q", conn.IsBlocked(), conn.BlockedReason())
+	}
+
+	blockings <- amqp091.Blocking{Active: false}
+	closes <- &amqp091.Error{Code: amqp091.ConnectionForced, Reason: "broker shutdown", Server: true}
+	close(blockings)
+	close(closes)
+	<-done
+
+	if conn.IsBlocked() {
+		t.Error("connection should no longer be blocked")
+	}
+	for _, want := range []string{"connection blocked by broker", "connection unblocked by broker", "connection closed"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs missing %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestPublisherFailsFastWhenBlocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		failFast bool
+		wantSent int
+	}{
+		{"fail fast", true, 0},
+		{"wait", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := newTestTracer()
+			conn := NewConnection(nil, ConnectionConfig{})
+			conn.events.handleBlocked(amqp091.Blocking{Active: true, Reason: "disk alarm"})
+
+			publisher := NewPublisher(PublisherConfig{Tracer: tracer, FailFastWhenBlocked: tt.failFast})
+			publisher.conn = conn
+
+			var sent []sentMessage
+			err := publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent))
+
+			var blocked *ConnectionBlockedError
+			if tt.failFast && (!errors.As(err, &blocked) || !errors.Is(err, ErrConnectionBlocked) || blocked.Reason != "disk alarm") {
+				t.Errorf("publish() error = %v, want %v", err, ErrConnectionBlocked)
+			}
+			if !tt.failFast && err != nil {
+				t.Errorf("publish() error = %v", err)
+			}
+			if len(sent) != tt.wantSent {
+				t.Errorf("sent %d messages, want %d", len(sent), tt.wantSent)
+			}
+
+			span := recorder.Ended()[0]
+			if len(span.Events()) == 0 || span.Events()[0].Name != "connection.blocked" {
+				t.Error("publish span should record a connection.blocked event")
+			}
+			found := false
+			for _, attr := range span.Attributes() {
+				if string(attr.Key) == internal.MessagingOrbConnectionBlocked {
+					found = true
+				}
+			}
+			if !found {
+				t.Error("publish span should be marked as blocked")
+			}
+		})
+	}
+}
+
+func TestChannelFlowNotifications(t *testing.T) {
+	var logs bytes.Buffer
+	events := newConnectionEvents(nil, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	flows := make(chan bool)
+	closes := make(chan *amqp091.Error)
+	done := make(chan struct{})
+	go func() {
+		events.watchChannel(flows, closes)
+		close(done)
+	}()
+
+	flows <- false
+	flows <- false
+	flows <- true
+	close(flows)
+	close(closes)
+	<-done
+
+	if got := strings.Count(logs.String(), "channel flow paused"); got != 1 {
+		t.Errorf("logged %d flow pauses, want 1", got)
+	}
+	if !strings.Contains(logs.String(), "channel flow resumed") {
+		t.Error("logs should record the resumed flow")
+	}
+}
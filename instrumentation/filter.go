@@ -0,0 +1,50 @@
+package instrumentation
+
+import (
+	"context"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// FilterMode decides how a single publish or delivery is instrumented.
+type FilterMode int
+
+const (
+	// FilterRecord creates a span as usual.
+	FilterRecord FilterMode = iota
+	// FilterSkip creates no span; trace context is still propagated.
+	FilterSkip
+	// FilterRecordOnErrorOrSlow only creates a span, backdated to the start of
+	// the operation, when the operation fails or exceeds the configured
+	// latency threshold.
+	FilterRecordOnErrorOrSlow
+)
+
+type PublishFilter func(exchange, routingKey string, msg *amqp091.Publishing) FilterMode
+
+type ConsumeFilter func(queueName string, delivery *amqp091.Delivery) FilterMode
+
+func nonRecordingSpan() trace.Span {
+	return trace.SpanFromContext(context.Background())
+}
+
+func shouldRecordDeferred(mode FilterMode, err error, elapsed, threshold time.Duration) bool {
+	if mode != FilterRecordOnErrorOrSlow {
+		return false
+	}
+	return err != nil || isSlow(elapsed, threshold)
+}
+
+func isSlow(elapsed, threshold time.Duration) bool {
+	return threshold > 0 && elapsed >= threshold
+}
+
+func deferredSpanAttributes(elapsed, threshold time.Duration) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.Bool(internal.MessagingOrbLatencyExceeded, isSlow(elapsed, threshold)),
+	)
+}
@@ -0,0 +1,152 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type fakeAcknowledger struct {
+	acked   int
+	nacked  int
+	requeue bool
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked++
+	return nil
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	a.nacked++
+	a.requeue = requeue
+	return nil
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func newTestTracer() (trace.Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return provider.Tracer("test"), recorder
+}
+
+func TestConsumerFilterSkip(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tracer, recorder := newTestTracer()
+	consumer := NewConsumer(ConsumerConfig{
+		Tracer: tracer,
+		Filter: func(queueName string, delivery *amqp091.Delivery) FilterMode {
+			if queueName == "health-check" {
+				return FilterSkip
+			}
+			return FilterRecord
+		},
+	})
+
+	parentCtx, parent := tracer.Start(context.Background(), "producer")
+	headers := amqp091.Table{}
+	NewPropagator().InjectToHeaders(parentCtx, headers)
+	parent.End()
+
+	ack := &fakeAcknowledger{}
+	var handlerSpan trace.SpanContext
+	err := consumer.ProcessDelivery(context.Background(), "health-check",
+		amqp091.Delivery{Acknowledger: ack, Headers: headers},
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			handlerSpan = trace.SpanContextFromContext(ctx)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ProcessDelivery() error = %v", err)
+	}
+
+	if got := len(recorder.Ended()); got != 1 {
+		t.Errorf("ended spans = %d, want only the producer span", got)
+	}
+	if ack.acked != 1 {
+		t.Errorf("acked = %d, want 1", ack.acked)
+	}
+	if handlerSpan.TraceID() != parent.SpanContext().TraceID() {
+		t.Error("skipped delivery should still propagate the producer trace context")
+	}
+}
+
+func TestConsumerFilterRecordOnErrorOrSlow(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   MessageHandler
+		wantSpans int
+		wantSlow  bool
+	}{
+		{
+			name:      "fast success",
+			handler:   func(ctx context.Context, delivery amqp091.Delivery) error { return nil },
+			wantSpans: 0,
+		},
+		{
+			name: "failure",
+			handler: func(ctx context.Context, delivery amqp091.Delivery) error {
+				return errors.New("boom")
+			},
+			wantSpans: 1,
+		},
+		{
+			name: "slow success",
+			handler: func(ctx context.Context, delivery amqp091.Delivery) error {
+				time.Sleep(30 * time.Millisecond)
+				return nil
+			},
+			wantSpans: 1,
+			wantSlow:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer, recorder := newTestTracer()
+			consumer := NewConsumer(ConsumerConfig{
+				Tracer: tracer,
+				Filter: func(queueName string, delivery *amqp091.Delivery) FilterMode {
+					return FilterRecordOnErrorOrSlow
+				},
+				LatencyThreshold: 20 * time.Millisecond,
+			})
+
+			_ = consumer.ProcessDelivery(context.Background(), "orders",
+				amqp091.Delivery{Acknowledger: &fakeAcknowledger{}}, tt.handler)
+
+			spans := recorder.Ended()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("ended spans = %d, want %d", len(spans), tt.wantSpans)
+			}
+			if tt.wantSpans == 0 {
+				return
+			}
+
+			slow := false
+			for _, attr := range spans[0].Attributes() {
+				if attr.Key == internal.MessagingOrbLatencyExceeded {
+					slow = attr.Value.AsBool()
+				}
+			}
+			if slow != tt.wantSlow {
+				t.Errorf("%s = %v, want %v", internal.MessagingOrbLatencyExceeded, slow, tt.wantSlow)
+			}
+			if spans[0].EndTime().Sub(spans[0].StartTime()) <= 0 {
+				t.Error("deferred span should be backdated to the start of processing")
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package instrumentation
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// HeaderCaptureConfig records selected message headers as
+// messaging.header.<name> span attributes.
+type HeaderCaptureConfig struct {
+	// Headers lists glob patterns, e.g. "x-tenant" or "x-event-*", matched
+	// case-insensitively against header names.
+	Headers []string
+	// Redactor may replace or mask a captured value. Returning an invalid
+	// attribute.Value drops the header.
+	Redactor func(name string, value attribute.Value) attribute.Value
+}
+
+func (c *HeaderCaptureConfig) attributes(headers amqp091.Table) []attribute.KeyValue {
+	if len(headers) == 0 || len(c.Headers) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if c.matches(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	attrs := make([]attribute.KeyValue, 0, len(names))
+	for _, name := range names {
+		value, ok := internal.HeaderValue(headers[name])
+		if !ok {
+			continue
+		}
+		if c.Redactor != nil {
+			value = c.Redactor(name, value)
+			if value.Type() == attribute.INVALID {
+				continue
+			}
+		}
+		attrs = append(attrs, attribute.KeyValue{Key: internal.HeaderAttributeKey(name), Value: value})
+	}
+	return attrs
+}
+
+func (c *HeaderCaptureConfig) matches(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range c.Headers {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+package instrumentation
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHeaderCaptureAllowlist(t *testing.T) {
+	config := &HeaderCaptureConfig{Headers: []string{"x-tenant", "X-Event-*"}}
+
+	attrs := config.attributes(amqp091.Table{
+		"x-tenant":         "acme",
+		"x-event-type":     "order.created",
+		"x-schema-version": int32(3),
+		"traceparent":      "00-abc",
+	})
+
+	want := []attribute.KeyValue{
+		attribute.String("messaging.header.x-event-type", "order.created"),
+		attribute.String("messaging.header.x-tenant", "acme"),
+	}
+	if len(attrs) != len(want) {
+		t.Fatalf("attributes() = %v, want %v", attrs, want)
+	}
+	for i := range want {
+		if attrs[i] != want[i] {
+			t.Errorf("attributes()[%d] = %v, want %v", i, attrs[i], want[i])
+		}
+	}
+}
+
+func TestHeaderCaptureRedactor(t *testing.T) {
+	config := &HeaderCaptureConfig{
+		Headers: []string{"*"},
+		Redactor: func(name string, value attribute.Value) attribute.Value {
+			switch name {
+			case "x-user-email":
+				return attribute.StringValue("<redacted>")
+			case "x-api-key":
+				return attribute.Value{}
+			}
+			return value
+		},
+	}
+
+	attrs := config.attributes(amqp091.Table{
+		"x-user-email": "jane@example.com",
+		"x-api-key":    "secret",
+	})
+
+	if len(attrs) != 1 {
+		t.Fatalf("attributes() = %v, want only the redacted email", attrs)
+	}
+	if attrs[0] != attribute.String("messaging.header.x-user-email", "<redacted>") {
+		t.Errorf("attributes()[0] = %v, want redacted email", attrs[0])
+	}
+}
@@ -0,0 +1,215 @@
+package instrumentation
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DedupStore remembers the keys of messages that were handled successfully.
+// Reserve must be atomic: of concurrent calls for the same key, across every
+// consumer sharing the store, at most one returns true until the key is
+// released or added. Shared stores should let reservations expire, so a
+// consumer that dies while handling a message does not hold its key forever.
+type DedupStore interface {
+	// Reserve claims key for handling. It returns false when key has been
+	// handled or is reserved by another handler.
+	Reserve(ctx context.Context, key string) (bool, error)
+	// Add records key as handled, replacing its reservation.
+	Add(ctx context.Context, key string) error
+	// Release drops the reservation of a message whose handler failed.
+	Release(ctx context.Context, key string) error
+}
+
+type IdempotencyConfig struct {
+	Store DedupStore
+	// Key extracts the deduplication key. Defaults to the MessageId.
+	// Deliveries with an empty key are always handled.
+	Key func(delivery *amqp091.Delivery) string
+}
+
+// IdempotencyMiddleware acknowledges duplicates of already handled messages
+// without calling the handler, and marks their spans with
+// messaging.orb.duplicate. The key is reserved before the handler runs, so a
+// copy delivered while another is being handled is a duplicate too; if that
+// handler fails, the reservation is released and the failed copy is retried
+// or dead-lettered as usual. Store errors are recorded on the span and the
+// message is handled as if it were new.
+func IdempotencyMiddleware(config IdempotencyConfig) Middleware {
+	if config.Key == nil {
+		config.Key = func(delivery *amqp091.Delivery) string {
+			return delivery.MessageId
+		}
+	}
+
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			key := config.Key(&delivery)
+			if key == "" {
+				return next(ctx, delivery)
+			}
+
+			span := trace.SpanFromContext(ctx)
+			reserved, err := config.Store.Reserve(ctx, key)
+			if err != nil {
+				span.RecordError(fmt.Errorf("failed to reserve message in deduplication store: %w", err))
+				reserved = true
+			}
+			if !reserved {
+				span.SetAttributes(attribute.Bool(internal.MessagingOrbDuplicate, true))
+				return nil
+			}
+
+			added := false
+			defer func() {
+				if added {
+					return
+				}
+				if err := config.Store.Release(ctx, key); err != nil {
+					span.RecordError(fmt.Errorf("failed to release message in deduplication store: %w", err))
+				}
+			}()
+
+			if err := next(ctx, delivery); err != nil {
+				return err
+			}
+			if err := config.Store.Add(ctx, key); err != nil {
+				span.RecordError(fmt.Errorf("failed to record message in deduplication store: %w", err))
+				return nil
+			}
+			added = true
+			return nil
+		}
+	}
+}
+
+// MemoryDedupStore keeps keys in memory, evicting the least recently used
+// key beyond its capacity and dropping keys older than its TTL. Reservations
+// are held until they are released or added.
+type MemoryDedupStore struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	order    *list.List
+	reserved map[string]struct{}
+}
+
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryDedupStore creates a store holding at most capacity keys for ttl
+// each. A capacity or ttl of zero means unbounded.
+func NewMemoryDedupStore(capacity int, ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		reserved: make(map[string]struct{}),
+	}
+}
+
+func (s *MemoryDedupStore) Contains(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.containsLocked(key), nil
+}
+
+func (s *MemoryDedupStore) Reserve(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reserved[key]; ok || s.containsLocked(key) {
+		return false, nil
+	}
+	s.reserved[key] = struct{}{}
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.reserved, key)
+	return nil
+}
+
+func (s *MemoryDedupStore) Add(ctx context.Context, key string) error {
+	var expires time.Time
+	if s.ttl > 0 {
+		expires = s.now().Add(s.ttl)
+	}
+	s.add(key, expires)
+	return nil
+}
+
+func (s *MemoryDedupStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryDedupStore) add(key string, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reserved, key)
+	if element, ok := s.entries[key]; ok {
+		element.Value.(*dedupEntry).expires = expires
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&dedupEntry{key: key, expires: expires})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		s.removeLocked(s.order.Back())
+	}
+}
+
+// live returns the unexpired entries, oldest first.
+func (s *MemoryDedupStore) live() []dedupEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]dedupEntry, 0, s.order.Len())
+	for element := s.order.Back(); element != nil; element = element.Prev() {
+		if entry := element.Value.(*dedupEntry); !s.expired(entry) {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries
+}
+
+func (s *MemoryDedupStore) containsLocked(key string) bool {
+	element, ok := s.entries[key]
+	if !ok {
+		return false
+	}
+	if s.expired(element.Value.(*dedupEntry)) {
+		s.removeLocked(element)
+		return false
+	}
+	s.order.MoveToFront(element)
+	return true
+}
+
+func (s *MemoryDedupStore) expired(entry *dedupEntry) bool {
+	return !entry.expires.IsZero() && !s.now().Before(entry.expires)
+}
+
+func (s *MemoryDedupStore) removeLocked(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*dedupEntry).key)
+}
@@ -0,0 +1,237 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestIdempotencyMiddlewareSkipsDuplicates(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	calls := 0
+	fail := true
+	consumer := NewConsumer(ConsumerConfig{
+		Tracer: tracer,
+		Middlewares: []Middleware{
+			IdempotencyMiddleware(IdempotencyConfig{Store: NewMemoryDedupStore(10, time.Hour)}),
+		},
+	})
+	handler := func(ctx context.Context, delivery amqp091.Delivery) error {
+		calls++
+		if fail {
+			return errors.New("transient")
+		}
+		return nil
+	}
+
+	process := func() *fakeAcknowledger {
+		ack := &fakeAcknowledger{}
+		_ = consumer.ProcessDelivery(context.Background(), "orders",
+			amqp091.Delivery{Acknowledger: ack, MessageId: "msg-1"}, handler)
+		return ack
+	}
+
+	process()
+	fail = false
+	process()
+	ack := process()
+
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+	if ack.acked != 1 {
+		t.Error("duplicate should be acked")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want 3", len(spans))
+	}
+	for i, span := range spans {
+		duplicate := false
+		for _, attr := range span.Attributes() {
+			if string(attr.Key) == internal.MessagingOrbDuplicate {
+				duplicate = attr.Value.AsBool()
+			}
+		}
+		if want := i == 2; duplicate != want {
+			t.Errorf("span %d duplicate = %v, want %v", i, duplicate, want)
+		}
+	}
+}
+
+func TestIdempotencyMiddlewareConcurrentDuplicates(t *testing.T) {
+	store := NewMemoryDedupStore(10, time.Hour)
+	middleware := IdempotencyMiddleware(IdempotencyConfig{Store: store})
+
+	var calls atomic.Int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	handler := middleware(func(ctx context.Context, delivery amqp091.Delivery) error {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+		}
+		return nil
+	})
+
+	// The first copy is still being handled when the others arrive, as with
+	// redeliveries to two consumers sharing the store.
+	done := make(chan error)
+	go func() {
+		done <- handler(context.Background(), amqp091.Delivery{MessageId: "msg-1"})
+	}()
+	<-entered
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := handler(context.Background(), amqp091.Delivery{MessageId: "msg-1"}); err != nil {
+				t.Errorf("duplicate handler error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("handler error = %v", err)
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler calls = %d, want 1", n)
+	}
+	if ok, _ := store.Contains(context.Background(), "msg-1"); !ok {
+		t.Error("handled key should be recorded")
+	}
+}
+
+func TestIdempotencyMiddlewareReleasesFailedKeys(t *testing.T) {
+	store := NewMemoryDedupStore(10, time.Hour)
+	handler := IdempotencyMiddleware(IdempotencyConfig{Store: store})(
+		func(ctx context.Context, delivery amqp091.Delivery) error {
+			panic("boom")
+		})
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = handler(context.Background(), amqp091.Delivery{MessageId: "msg-1"})
+	}()
+
+	if ok, _ := store.Reserve(context.Background(), "msg-1"); !ok {
+		t.Error("a panicking handler should release its reservation")
+	}
+}
+
+func TestIdempotencyMiddlewareKey(t *testing.T) {
+	calls := 0
+	handler := IdempotencyMiddleware(IdempotencyConfig{
+		Store: NewMemoryDedupStore(0, 0),
+		Key: func(delivery *amqp091.Delivery) string {
+			return delivery.CorrelationId
+		},
+	})(func(ctx context.Context, delivery amqp091.Delivery) error {
+		calls++
+		return nil
+	})
+
+	_ = handler(context.Background(), amqp091.Delivery{MessageId: "a", CorrelationId: "order-1"})
+	_ = handler(context.Background(), amqp091.Delivery{MessageId: "b", CorrelationId: "order-1"})
+	_ = handler(context.Background(), amqp091.Delivery{})
+	_ = handler(context.Background(), amqp091.Delivery{})
+
+	if calls != 3 {
+		t.Errorf("handler calls = %d, want 3", calls)
+	}
+}
+
+func TestMemoryDedupStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1000, 0)
+	store := NewMemoryDedupStore(2, time.Minute)
+	store.now = func() time.Time { return now }
+
+	_ = store.Add(ctx, "a")
+	_ = store.Add(ctx, "b")
+	_, _ = store.Contains(ctx, "a")
+	_ = store.Add(ctx, "c")
+
+	if ok, _ := store.Contains(ctx, "b"); ok {
+		t.Error("least recently used key should be evicted")
+	}
+	if ok, _ := store.Contains(ctx, "a"); !ok {
+		t.Error("recently used key should be kept")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := store.Contains(ctx, "c"); ok {
+		t.Error("expired key should not be contained")
+	}
+}
+
+func TestFileDedupStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.log")
+
+	store, err := NewFileDedupStore(path, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore() error = %v", err)
+	}
+	_ = store.Add(ctx, "msg 1")
+	_ = store.Add(ctx, "msg\n2")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewFileDedupStore(path, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore() error = %v", err)
+	}
+	defer store.Close()
+
+	for _, key := range []string{"msg 1", "msg\n2"} {
+		if ok, _ := store.Contains(ctx, key); !ok {
+			t.Errorf("Contains(%q) = false after reopen", key)
+		}
+	}
+	if ok, _ := store.Contains(ctx, "msg 3"); ok {
+		t.Error("unknown key should not be contained")
+	}
+
+	// Appends are compacted while the store is open, bounding the file.
+	const capacity = 10
+	bounded, err := NewFileDedupStore(filepath.Join(t.TempDir(), "bounded.log"), capacity, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileDedupStore() error = %v", err)
+	}
+	defer bounded.Close()
+
+	for i := 0; i < 50*capacity; i++ {
+		if err := bounded.Add(ctx, fmt.Sprintf("msg-%d", i)); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(bounded.path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if lines := bytes.Count(data, []byte("\n")); lines > 2*capacity {
+		t.Errorf("file has %d lines, want at most %d", lines, 2*capacity)
+	}
+	if ok, _ := bounded.Contains(ctx, fmt.Sprintf("msg-%d", 50*capacity-1)); !ok {
+		t.Error("latest key should be contained")
+	}
+}
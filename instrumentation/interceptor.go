@@ -0,0 +1,63 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrPublishVetoed can be returned, optionally wrapped, by an interceptor
+// that decides a message must not be published.
+var ErrPublishVetoed = errors.New("publish vetoed by interceptor")
+
+type PublishFunc func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error
+
+// PublishInterceptor runs inside the producer span after trace context has
+// been injected. It may mutate msg, veto the publish by returning without
+// calling next, or observe the error returned by next.
+type PublishInterceptor func(
+	ctx context.Context,
+	exchange, routingKey string,
+	msg *amqp091.Publishing,
+	next PublishFunc,
+) error
+
+func (p *Publisher) intercepted(send PublishFunc) PublishFunc {
+	next := send
+	for i := len(p.config.Interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := p.config.Interceptors[i], next
+		next = func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+			return interceptor(ctx, exchange, routingKey, msg, inner)
+		}
+	}
+	return next
+}
+
+// MessageIDInterceptor assigns a message ID from generate to messages that
+// do not carry one.
+func MessageIDInterceptor(generate func() string) PublishInterceptor {
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+		if msg.MessageId == "" {
+			msg.MessageId = generate()
+			attrs := []attribute.KeyValue{attribute.String(internal.MessagingMessageID, msg.MessageId)}
+			trace.SpanFromContext(ctx).SetAttributes(internal.ConvertAttributes(semconvModeFromContext(ctx), attrs)...)
+		}
+		return next(ctx, exchange, routingKey, msg)
+	}
+}
+
+// HeadersInterceptor adds headers that are not already set on the message.
+func HeadersInterceptor(headers amqp091.Table) PublishInterceptor {
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+		for key, value := range headers {
+			if _, ok := msg.Headers[key]; !ok {
+				msg.Headers[key] = value
+			}
+		}
+		return next(ctx, exchange, routingKey, msg)
+	}
+}
@@ -0,0 +1,202 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type sentMessage struct {
+	exchange   string
+	routingKey string
+	msg        amqp091.Publishing
+}
+
+func recordingSend(sent *[]sentMessage) PublishFunc {
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		*sent = append(*sent, sentMessage{exchange: exchange, routingKey: routingKey, msg: *msg})
+		return nil
+	}
+}
+
+func TestPublisherInterceptorsMutateMessage(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	var order []string
+	var interceptorSpan trace.SpanContext
+	publisher := NewPublisher(PublisherConfig{
+		Tracer: tracer,
+		Interceptors: []PublishInterceptor{
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+				order = append(order, "first")
+				interceptorSpan = trace.SpanContextFromContext(ctx)
+				return next(ctx, exchange, routingKey, msg)
+			},
+			MessageIDInterceptor(func() string { return "generated-id" }),
+			HeadersInterceptor(amqp091.Table{"x-tenant": "acme", "x-source": "default"}),
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+				order = append(order, "last")
+				return next(ctx, exchange, routingKey, msg)
+			},
+		},
+	})
+
+	var sent []sentMessage
+	msg := amqp091.Publishing{Headers: amqp091.Table{"x-source": "billing"}}
+	if err := publisher.publish(context.Background(), "orders", "order.created", &msg, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	if strings.Join(order, ",") != "first,last" {
+		t.Errorf("interceptor order = %v, want first,last", order)
+	}
+	if len(sent) != 1 {
+		t.Fatalf("sent = %d messages, want 1", len(sent))
+	}
+	got := sent[0].msg
+	if got.MessageId != "generated-id" {
+		t.Errorf("MessageId = %q, want generated-id", got.MessageId)
+	}
+	if got.Headers["x-tenant"] != "acme" || got.Headers["x-source"] != "billing" {
+		t.Errorf("Headers = %v, want tenant added and source preserved", got.Headers)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || interceptorSpan.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Fatal("interceptors should run inside the producer span")
+	}
+	found := false
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == internal.MessagingMessageID && attr.Value.AsString() == "generated-id" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("generated message ID should be recorded on the span")
+	}
+}
+
+func TestMessageIDInterceptorFollowsSemconvMode(t *testing.T) {
+	tests := []struct {
+		mode SemconvMode
+		want []string
+	}{
+		{mode: SemconvOld, want: []string{internal.MessagingMessageID}},
+		{mode: SemconvStable, want: []string{internal.MessagingMessageIDStable}},
+		{mode: SemconvDup, want: []string{internal.MessagingMessageIDStable, internal.MessagingMessageID}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			tracer, recorder := newTestTracer()
+			publisher := NewPublisher(PublisherConfig{
+				Tracer:       tracer,
+				SemconvMode:  tt.mode,
+				Interceptors: []PublishInterceptor{MessageIDInterceptor(func() string { return "generated-id" })},
+			})
+
+			var sent []sentMessage
+			if err := publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent)); err != nil {
+				t.Fatalf("publish() error = %v", err)
+			}
+
+			var got []string
+			for _, attr := range recorder.Ended()[0].Attributes() {
+				if attr.Value.AsString() == "generated-id" {
+					got = append(got, string(attr.Key))
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("message ID attributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublisherInterceptorVeto(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	var observed []error
+	publisher := NewPublisher(PublisherConfig{
+		Tracer: tracer,
+		Interceptors: []PublishInterceptor{
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+				err := next(ctx, exchange, routingKey, msg)
+				observed = append(observed, err)
+				return err
+			},
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+				if len(msg.Body) == 0 {
+					return fmt.Errorf("empty body: %w", ErrPublishVetoed)
+				}
+				return next(ctx, exchange, routingKey, msg)
+			},
+		},
+		OnPublishError: func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, err error) {
+			t.Error("vetoed publishes should not be reported as publish failures")
+		},
+	})
+
+	var sent []sentMessage
+	err := publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent))
+	if !errors.Is(err, ErrPublishVetoed) {
+		t.Errorf("publish() error = %v, want %v", err, ErrPublishVetoed)
+	}
+	if len(sent) != 0 {
+		t.Error("vetoed message should not be sent")
+	}
+	if len(observed) != 1 || !errors.Is(observed[0], ErrPublishVetoed) {
+		t.Errorf("observed = %v, want veto error", observed)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatal("vetoed publish should end the producer span with an error status")
+	}
+}
+
+func TestPublisherSpanStartOptionsFromContext(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	publisher := NewPublisher(PublisherConfig{Tracer: tracer})
+
+	ctx := ContextWithSpanStartOptions(context.Background(),
+		trace.WithAttributes(attribute.String("custom", "value")))
+
+	send := func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		_, span := publisher.startSpan(ctx, exchange, routingKey, msg)
+		span.End()
+		return nil
+	}
+	if err := publisher.publish(ctx, "orders", "order.created", &amqp091.Publishing{}, send); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "custom" {
+			t.Error("span start options should not leak into nested spans")
+		}
+	}
+	found := false
+	for _, attr := range spans[1].Attributes() {
+		if attr.Key == "custom" && attr.Value.AsString() == "value" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("publish span should carry the options from the context")
+	}
+}
@@ -0,0 +1,146 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps a MessageHandler. Middlewares configured on a Consumer run
+// inside the consumer span, so trace.SpanFromContext returns that span.
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain composes middlewares so that the first one is the outermost.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+var ErrHandlerPanic = errors.New("message handler panicked")
+
+// RecoveryMiddleware turns a handler panic into a dead-lettered error so a
+// poison message cannot crash the consumer or be redelivered forever.
+func RecoveryMiddleware() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					trace.SpanFromContext(ctx).AddEvent("handler.panic", trace.WithAttributes(
+						attribute.String("exception.message", fmt.Sprint(r)),
+						attribute.String("exception.stacktrace", string(debug.Stack())),
+					))
+					err = DeadLetter(fmt.Errorf("%w: %v", ErrHandlerPanic, r))
+				}
+			}()
+			return next(ctx, delivery)
+		}
+	}
+}
+
+// TimeoutMiddleware cancels the handler context after timeout. Handlers must
+// honour ctx for the timeout to take effect.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			err := next(ctx, delivery)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				trace.SpanFromContext(ctx).AddEvent("handler.timeout", trace.WithAttributes(
+					attribute.Int64("orb.handler.timeout_ms", timeout.Milliseconds()),
+				))
+			}
+			return err
+		}
+	}
+}
+
+// LoggingMiddleware logs the outcome of every handled message.
+func LoggingMiddleware(logger *slog.Logger) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			start := time.Now()
+			err := next(ctx, delivery)
+
+			attrs := []slog.Attr{slog.Duration("duration", time.Since(start))}
+			if err != nil {
+				attrs = append(attrs, slog.Any("error", err))
+				logger.LogAttrs(ctx, slog.LevelError, "message handler failed", attrs...)
+			} else {
+				logger.LogAttrs(ctx, slog.LevelInfo, "message handled", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+// MetricsMiddleware records handler duration and outcome. A nil meter uses the
+// global meter provider.
+func MetricsMiddleware(meter metric.Meter) Middleware {
+	if meter == nil {
+		meter = otel.Meter(internal.TracerName)
+	}
+	duration, _ := meter.Float64Histogram(
+		"orb.consumer.process.duration",
+		metric.WithDescription("Duration of message handler execution"),
+		metric.WithUnit("s"),
+	)
+	messages, _ := meter.Int64Counter(
+		"orb.consumer.messages",
+		metric.WithDescription("Number of messages handled"),
+	)
+
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			start := time.Now()
+			err := next(ctx, delivery)
+
+			outcome := "success"
+			if err != nil {
+				outcome = "error"
+			}
+			queueName := delivery.RoutingKey
+			if info, ok := DeliveryInfoFromContext(ctx); ok && info.Queue != "" {
+				queueName = info.Queue
+			}
+			attrs := metric.WithAttributes(
+				attribute.String(internal.MessagingDestination, queueName),
+				attribute.String("outcome", outcome),
+			)
+			duration.Record(ctx, time.Since(start).Seconds(), attrs)
+			messages.Add(ctx, 1, attrs)
+			return err
+		}
+	}
+}
+
+// ValidationMiddleware rejects messages for which validate returns an error.
+// Invalid messages are dead-lettered without reaching the handler.
+func ValidationMiddleware(validate func(ctx context.Context, delivery amqp091.Delivery) error) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			if err := validate(ctx, delivery); err != nil {
+				trace.SpanFromContext(ctx).AddEvent("validation.failed", trace.WithAttributes(
+					attribute.String("exception.message", err.Error()),
+				))
+				return DeadLetter(fmt.Errorf("validation failed: %w", err))
+			}
+			return next(ctx, delivery)
+		}
+	}
+}
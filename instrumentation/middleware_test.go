@@ -0,0 +1,140 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, delivery amqp091.Delivery) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, delivery)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	handler := Chain(record("outer"), record("inner"))(func(ctx context.Context, delivery amqp091.Delivery) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	_ = handler(context.Background(), amqp091.Delivery{})
+
+	want := "outer before,inner before,handler,inner after,outer after"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestConsumerMiddlewaresRunInsideSpan(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	var middlewareSpan trace.SpanContext
+	consumer := NewConsumer(ConsumerConfig{
+		Tracer: tracer,
+		Middlewares: []Middleware{
+			func(next MessageHandler) MessageHandler {
+				return func(ctx context.Context, delivery amqp091.Delivery) error {
+					middlewareSpan = trace.SpanContextFromContext(ctx)
+					return next(ctx, delivery)
+				}
+			},
+		},
+	})
+
+	_ = consumer.ProcessDelivery(context.Background(), "orders", amqp091.Delivery{Acknowledger: &fakeAcknowledger{}},
+		func(ctx context.Context, delivery amqp091.Delivery) error { return nil })
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	if middlewareSpan.SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("middleware should run inside the consumer span")
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	handler := RecoveryMiddleware()(func(ctx context.Context, delivery amqp091.Delivery) error {
+		panic("nil map")
+	})
+
+	err := handler(context.Background(), amqp091.Delivery{})
+	if !errors.Is(err, ErrHandlerPanic) {
+		t.Errorf("error = %v, want %v", err, ErrHandlerPanic)
+	}
+	if !IsDeadLetter(err) {
+		t.Error("panics should be dead-lettered")
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	handler := TimeoutMiddleware(10 * time.Millisecond)(func(ctx context.Context, delivery amqp091.Delivery) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	err := handler(context.Background(), amqp091.Delivery{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	called := false
+	handler := ValidationMiddleware(func(ctx context.Context, delivery amqp091.Delivery) error {
+		if delivery.ContentType != "application/json" {
+			return errors.New("unsupported content type")
+		}
+		return nil
+	})(func(ctx context.Context, delivery amqp091.Delivery) error {
+		called = true
+		return nil
+	})
+
+	err := handler(context.Background(), amqp091.Delivery{ContentType: "text/plain"})
+	if !IsDeadLetter(err) || called {
+		t.Errorf("invalid message: error = %v, handler called = %v", err, called)
+	}
+
+	if err := handler(context.Background(), amqp091.Delivery{ContentType: "application/json"}); err != nil || !called {
+		t.Errorf("valid message: error = %v, handler called = %v", err, called)
+	}
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(buf, nil))
+
+	handler := LoggingMiddleware(logger)(func(ctx context.Context, delivery amqp091.Delivery) error {
+		return errors.New("boom")
+	})
+	_ = handler(context.Background(), amqp091.Delivery{})
+
+	if !strings.Contains(buf.String(), "message handler failed") || !strings.Contains(buf.String(), "boom") {
+		t.Errorf("log output = %q, want handler failure", buf.String())
+	}
+}
+
+func TestMetricsMiddlewarePassesThrough(t *testing.T) {
+	want := errors.New("boom")
+	handler := MetricsMiddleware(nil)(func(ctx context.Context, delivery amqp091.Delivery) error {
+		return want
+	})
+
+	if err := handler(context.Background(), amqp091.Delivery{}); err != want {
+		t.Errorf("error = %v, want %v", err, want)
+	}
+}
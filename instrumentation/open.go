@@ -0,0 +1,540 @@
+package instrumentation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultDialTimeout bounds connection attempts whose context has no
+// deadline and whose URL sets no connection_timeout.
+const DefaultDialTimeout = 30 * time.Second
+
+// ReconnectPolicy controls how often Open and Redial retry the dial when an
+// attempt fails. It does not restore a connection that is lost after it has
+// been opened; call Redial for that. The zero value makes a single attempt.
+type ReconnectPolicy struct {
+	// MaxAttempts is the total number of attempts. Values below 1 mean one
+	// attempt; use a negative value to retry until the context is done.
+	MaxAttempts int
+	// InitialInterval defaults to 100ms and doubles, with jitter, up to
+	// MaxInterval, which defaults to 30s.
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+}
+
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialInterval
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = 30 * time.Second
+	}
+
+	d := initial
+	for i := 1; i < attempt && d < maxInterval; i++ {
+		d *= 2
+	}
+	if d > maxInterval {
+		d = maxInterval
+	}
+	// Up to 20% jitter keeps clients from reconnecting in lockstep.
+	return d - time.Duration(rand.Int63n(int64(d)/5+1))
+}
+
+func (p ReconnectPolicy) retry(attempt int) bool {
+	return p.MaxAttempts < 0 || attempt < p.MaxAttempts
+}
+
+// Option configures Open.
+type Option func(*openOptions)
+
+type openOptions struct {
+	amqpConfig     amqp091.Config
+	tlsConfig      *tls.Config
+	connectionName string
+	config         ConnectionConfig
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	reconnect      ReconnectPolicy
+	dialTimeout    time.Duration
+}
+
+// WithAMQPConfig sets the amqp091 configuration used for the handshake. A
+// custom Config.Dial replaces the DNS lookup and TCP connect.
+func WithAMQPConfig(config amqp091.Config) Option {
+	return func(o *openOptions) {
+		o.amqpConfig = config
+	}
+}
+
+// WithTLS sets the TLS configuration for amqps URLs.
+func WithTLS(config *tls.Config) Option {
+	return func(o *openOptions) {
+		o.tlsConfig = config
+	}
+}
+
+// WithConnectionName sets the name shown for the connection in the RabbitMQ
+// management UI.
+func WithConnectionName(name string) Option {
+	return func(o *openOptions) {
+		o.connectionName = name
+	}
+}
+
+func WithConnectionConfig(config ConnectionConfig) Option {
+	return func(o *openOptions) {
+		o.config = config
+	}
+}
+
+func WithChannelConfig(config ChannelConfig) Option {
+	return func(o *openOptions) {
+		o.config.ChannelConfig = config
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *openOptions) {
+		o.config.Logger = logger
+	}
+}
+
+// WithTracerProvider is used for the connect span and for publishers and
+// consumers whose config has no Tracer.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *openOptions) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider is used for connection metrics unless the
+// ConnectionConfig has a Meter.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *openOptions) {
+		o.meterProvider = provider
+	}
+}
+
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(o *openOptions) {
+		o.reconnect = policy
+	}
+}
+
+// WithDialTimeout bounds each connection attempt, including the TLS and AMQP
+// handshakes.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(o *openOptions) {
+		o.dialTimeout = timeout
+	}
+}
+
+// Open connects to RabbitMQ. Every attempt is traced as a connect span with
+// child spans for the DNS lookup, TCP connect, TLS handshake and AMQP
+// handshake. Cancelling ctx aborts the attempt in progress.
+func Open(ctx context.Context, url string, opts ...Option) (*Connection, error) {
+	e, err := parseEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+	return open(ctx, e, opts)
+}
+
+// Redial opens a new connection to the endpoint and with the options of a
+// connection created by Open or one of the Dial functions.
+func (c *Connection) Redial(ctx context.Context) (*Connection, error) {
+	if c.endpoint == nil {
+		return nil, errors.New("connection was not opened with Open or Dial")
+	}
+	return open(ctx, c.endpoint, c.opts)
+}
+
+func open(ctx context.Context, e *endpoint, opts []Option) (*Connection, error) {
+	options := openOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	tracer := otel.Tracer(internal.TracerName)
+	if options.tracerProvider != nil {
+		tracer = options.tracerProvider.Tracer(internal.TracerName)
+	}
+	config := options.connectionConfig()
+
+	for attempt := 1; ; attempt++ {
+		conn, err := options.connect(ctx, tracer, e, attempt)
+		if err == nil {
+			c := newConnection(&e.uri, conn, config)
+			c.endpoint = e
+			c.opts = opts
+			return c, nil
+		}
+
+		if !options.reconnect.retry(attempt) || ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+		}
+
+		timer := time.NewTimer(options.reconnect.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", errors.Join(err, ctx.Err()))
+		case <-timer.C:
+		}
+	}
+}
+
+// endpoint is a parsed dial URL. The credentials are kept only in uri and
+// passed to amqp091 as SASL mechanisms, so the URL string carries none and
+// cannot leak into span attributes, errors or logs.
+type endpoint struct {
+	uri amqp091.URI
+	// url is the dial URL without user info.
+	url string
+}
+
+func parseEndpoint(rawURL string) (*endpoint, error) {
+	uri, err := amqp091.ParseURI(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AMQP URL: %w", withoutURL(err))
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AMQP URL: %w", withoutURL(err))
+	}
+	u.User = nil
+	return &endpoint{uri: uri, url: u.String()}, nil
+}
+
+// sasl picks the authentication mechanisms the same way amqp091 does for a
+// URL with credentials.
+func (e *endpoint) sasl() ([]amqp091.Authentication, error) {
+	if len(e.uri.AuthMechanism) == 0 {
+		return []amqp091.Authentication{e.uri.PlainAuth()}, nil
+	}
+
+	var sasl []amqp091.Authentication
+	for _, mechanism := range e.uri.AuthMechanism {
+		switch strings.ToUpper(mechanism) {
+		case "PLAIN":
+			sasl = append(sasl, e.uri.PlainAuth())
+		case "AMQPLAIN":
+			sasl = append(sasl, e.uri.AMQPlainAuth())
+		case "EXTERNAL":
+			sasl = append(sasl, &amqp091.ExternalAuth{})
+		default:
+			return nil, fmt.Errorf("unsupported auth_mechanism: %v", mechanism)
+		}
+	}
+	return sasl, nil
+}
+
+// withoutURL drops the URL, which may contain a password, from url.Parse
+// errors.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func (o *openOptions) connectionConfig() ConnectionConfig {
+	config := o.config
+	if config.Meter == nil && o.meterProvider != nil {
+		config.Meter = o.meterProvider.Meter(internal.TracerName)
+	}
+	if o.tracerProvider != nil {
+		tracer := o.tracerProvider.Tracer(internal.TracerName)
+		if config.ChannelConfig.PublisherConfig.Tracer == nil {
+			config.ChannelConfig.PublisherConfig.Tracer = tracer
+		}
+		if config.ChannelConfig.ConsumerConfig.Tracer == nil {
+			config.ChannelConfig.ConsumerConfig.Tracer = tracer
+		}
+	}
+	return config
+}
+
+func (o *openOptions) connect(
+	ctx context.Context,
+	tracer trace.Tracer,
+	e *endpoint,
+	attempt int,
+) (conn *amqp091.Connection, err error) {
+	uri, rawURL := e.uri, e.url
+
+	attrs := append(internal.URIAttributes(uri), attribute.Int(internal.MessagingOrbConnectAttempt, attempt))
+	if o.config.RecordUser {
+		attrs = append(attrs, internal.UserAttributes(uri)...)
+	}
+	ctx, span := tracer.Start(ctx, "connect",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	defer func() {
+		internal.SafeSetSpanStatus(span, err)
+		span.End()
+	}()
+
+	timeout := o.dialTimeout
+	if timeout <= 0 && uri.ConnectionTimeout > 0 {
+		timeout = time.Duration(uri.ConnectionTimeout) * time.Millisecond
+	}
+	if timeout <= 0 {
+		timeout = DefaultDialTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	config := o.amqpConfig
+	if config.Locale == "" {
+		config.Locale = "en_US"
+	}
+	if config.SASL == nil {
+		if config.SASL, err = e.sasl(); err != nil {
+			return nil, err
+		}
+	}
+	if o.connectionName != "" {
+		properties := amqp091.NewConnectionProperties()
+		for key, value := range config.Properties {
+			properties[key] = value
+		}
+		properties.SetClientConnectionName(o.connectionName)
+		config.Properties = properties
+	}
+
+	var tlsConfig *tls.Config
+	if uri.Scheme == "amqps" {
+		explicit := o.tlsConfig
+		if explicit == nil {
+			explicit = config.TLSClientConfig
+		}
+		if tlsConfig, err = tlsConfigFromURI(uri, explicit); err != nil {
+			return nil, err
+		}
+		// The TLS handshake happens in dial so it can be traced and
+		// cancelled, so amqp091 must not start another one.
+		if rawURL, err = plainURL(rawURL, uri.Port); err != nil {
+			return nil, err
+		}
+	}
+
+	var netConn atomic.Pointer[net.Conn]
+	config.Dial = func(network, addr string) (net.Conn, error) {
+		c, err := o.dial(ctx, tracer, network, addr, tlsConfig)
+		if err == nil {
+			netConn.Store(&c)
+		}
+		return c, err
+	}
+
+	// Abort the AMQP handshake when ctx is done.
+	stop := context.AfterFunc(ctx, func() {
+		if c := netConn.Load(); c != nil {
+			_ = (*c).SetDeadline(time.Unix(1, 0))
+		}
+	})
+	defer stop()
+
+	conn, err = amqp091.DialConfig(rawURL, config)
+	if err != nil {
+		// The socket deadline can expire just before ctx does.
+		if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+			<-ctx.Done()
+		}
+		if ctx.Err() != nil {
+			err = errors.Join(err, ctx.Err())
+		}
+		return nil, err
+	}
+	span.SetAttributes(internal.AddrAttributes(conn.RemoteAddr(), conn.LocalAddr())...)
+	span.SetAttributes(internal.ServerPropertyAttributes(conn.Properties)...)
+	return conn, nil
+}
+
+// dial performs the DNS lookup, TCP connect and TLS handshake, each in its
+// own span. The AMQP handshake span ends once the first frame is read.
+func (o *openOptions) dial(
+	ctx context.Context,
+	tracer trace.Tracer,
+	network, addr string,
+	tlsConfig *tls.Config,
+) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if o.amqpConfig.Dial != nil {
+		_, span := tracer.Start(ctx, "tcp.connect")
+		conn, err = o.amqpConfig.Dial(network, addr)
+		internal.SafeSetSpanStatus(span, err)
+		span.End()
+	} else {
+		var addrs []string
+		if addrs, err = lookupHost(ctx, tracer, host); err == nil {
+			conn, err = dialTCP(ctx, tracer, network, addrs, port)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// amqp091 clears the deadline once the AMQP handshake completes.
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if tlsConfig != nil {
+		_, span := tracer.Start(ctx, "tls.handshake")
+		client := tls.Client(conn, tlsConfig)
+		err = client.HandshakeContext(ctx)
+		internal.SafeSetSpanStatus(span, err)
+		span.End()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = client
+	}
+
+	_, span := tracer.Start(ctx, "amqp.handshake")
+	return &handshakeConn{Conn: conn, span: span}, nil
+}
+
+func lookupHost(ctx context.Context, tracer trace.Tracer, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	_, span := tracer.Start(ctx, "dns.lookup", trace.WithAttributes(attribute.String(internal.ServerAddress, host)))
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	internal.SafeSetSpanStatus(span, err)
+	span.End()
+	return addrs, err
+}
+
+func dialTCP(ctx context.Context, tracer trace.Tracer, network string, addrs []string, port string) (net.Conn, error) {
+	ctx, span := tracer.Start(ctx, "tcp.connect")
+	defer span.End()
+
+	var dialer net.Dialer
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			span.SetAttributes(internal.AddrAttributes(conn.RemoteAddr(), nil)...)
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	err := errors.Join(errs...)
+	internal.SafeSetSpanStatus(span, err)
+	return nil, err
+}
+
+// handshakeConn ends the AMQP handshake span when the connection's deadline
+// is cleared, which amqp091 does once the handshake has completed.
+type handshakeConn struct {
+	net.Conn
+	span trace.Span
+	once sync.Once
+}
+
+func (c *handshakeConn) SetDeadline(t time.Time) error {
+	if t.IsZero() {
+		c.once.Do(func() { c.span.End() })
+	}
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *handshakeConn) Close() error {
+	c.once.Do(func() { c.span.End() })
+	return c.Conn.Close()
+}
+
+// ConnectionState keeps amqp091's Connection.ConnectionState working for TLS
+// connections.
+func (c *handshakeConn) ConnectionState() tls.ConnectionState {
+	if conn, ok := c.Conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		return conn.ConnectionState()
+	}
+	return tls.ConnectionState{}
+}
+
+// plainURL switches an amqps URL to amqp while keeping its port and query.
+func plainURL(rawURL string, port int) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse AMQP URL: %w", err)
+	}
+	u.Scheme = "amqp"
+	u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(port))
+	return u.String(), nil
+}
+
+// tlsConfigFromURI fills in the server name and, without an explicit
+// config, the certificates named by the URL's query parameters.
+func tlsConfigFromURI(uri amqp091.URI, config *tls.Config) (*tls.Config, error) {
+	if config != nil {
+		config = config.Clone()
+	} else {
+		config = &tls.Config{}
+		if uri.CACertFile != "" {
+			pem, err := os.ReadFile(uri.CACertFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			}
+			config.RootCAs = x509.NewCertPool()
+			config.RootCAs.AppendCertsFromPEM(pem)
+		}
+		if uri.CertFile != "" || uri.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(uri.CertFile, uri.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load client certificate: %w", err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+	}
+
+	if config.ServerName == "" {
+		config.ServerName = uri.ServerName
+	}
+	if config.ServerName == "" {
+		config.ServerName = uri.Host
+	}
+	return config, nil
+}
@@ -0,0 +1,412 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrChannelPoolClosed = errors.New("channel pool is closed")
+
+type ChannelPoolConfig struct {
+	MinSize     int
+	MaxSize     int
+	ConfirmMode bool
+	Meter       metric.Meter
+}
+
+type ChannelPoolStats struct {
+	Size    int
+	Idle    int
+	InUse   int
+	Waiting int
+	Created int64
+}
+
+// ChannelPool hands out instrumented channels to concurrent publishers so that
+// no two goroutines publish on the same AMQP channel at the same time.
+type ChannelPool struct {
+	config ChannelPoolConfig
+	open   func() (*Channel, error)
+
+	mu      sync.Mutex
+	idle    []*Channel
+	size    int
+	inUse   int
+	created int64
+	waiters []chan *Channel
+	closed  bool
+
+	inUseCounter   metric.Int64UpDownCounter
+	waitingCounter metric.Int64UpDownCounter
+	createdCounter metric.Int64Counter
+}
+
+func NewChannelPool(conn *Connection, config ChannelPoolConfig) (*ChannelPool, error) {
+	return newChannelPool(config, conn.ChannelWithTracing)
+}
+
+func (c *Connection) ChannelPool(config ChannelPoolConfig) (*ChannelPool, error) {
+	return NewChannelPool(c, config)
+}
+
+func newChannelPool(config ChannelPoolConfig, open func() (*Channel, error)) (*ChannelPool, error) {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1
+	}
+	if config.MinSize < 0 {
+		config.MinSize = 0
+	}
+	if config.MinSize > config.MaxSize {
+		return nil, fmt.Errorf("channel pool min size %d exceeds max size %d", config.MinSize, config.MaxSize)
+	}
+	if config.Meter == nil {
+		config.Meter = otel.Meter(internal.TracerName)
+	}
+
+	p := &ChannelPool{
+		config: config,
+		open:   open,
+	}
+	p.inUseCounter, _ = config.Meter.Int64UpDownCounter(
+		"orb.channel_pool.in_use",
+		metric.WithDescription("Number of pooled channels currently borrowed"),
+	)
+	p.waitingCounter, _ = config.Meter.Int64UpDownCounter(
+		"orb.channel_pool.waiting",
+		metric.WithDescription("Number of callers waiting for a pooled channel"),
+	)
+	p.createdCounter, _ = config.Meter.Int64Counter(
+		"orb.channel_pool.created",
+		metric.WithDescription("Number of channels opened by the pool"),
+	)
+
+	for i := 0; i < config.MinSize; i++ {
+		ch, err := p.newChannel()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.mu.Lock()
+		p.size++
+		p.idle = append(p.idle, ch)
+		p.mu.Unlock()
+	}
+
+	return p, nil
+}
+
+func (p *ChannelPool) Get(ctx context.Context) (*Channel, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrChannelPoolClosed
+	}
+
+	for len(p.idle) > 0 {
+		ch := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if !p.healthy(ch) {
+			p.size--
+			continue
+		}
+		p.borrowLocked()
+		p.mu.Unlock()
+		return ch, nil
+	}
+
+	if p.size < p.config.MaxSize {
+		p.size++
+		p.mu.Unlock()
+		return p.createBorrowed()
+	}
+
+	waiter := make(chan *Channel, 1)
+	p.waiters = append(p.waiters, waiter)
+	p.mu.Unlock()
+
+	ch, create, err := p.wait(ctx, waiter)
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		return p.createBorrowed()
+	}
+	return ch, nil
+}
+
+func (p *ChannelPool) Put(ch *Channel) {
+	if ch == nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.inUse--
+	p.inUseCounter.Add(context.Background(), -1)
+
+	if p.closed || !p.healthy(ch) {
+		p.size--
+		p.handOffSlotLocked()
+		p.mu.Unlock()
+		closeChannel(ch)
+		return
+	}
+
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.borrowLocked()
+		p.mu.Unlock()
+		waiter <- ch
+		return
+	}
+
+	p.idle = append(p.idle, ch)
+	p.mu.Unlock()
+}
+
+func (p *ChannelPool) Publish(
+	ctx context.Context,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	msg amqp091.Publishing,
+) error {
+	ch, err := p.Get(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Put(ch)
+
+	return ch.PublishWithTracing(ctx, exchange, routingKey, mandatory, immediate, msg)
+}
+
+func (p *ChannelPool) PublishWithConfirm(
+	ctx context.Context,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	msg amqp091.Publishing,
+) (*amqp091.DeferredConfirmation, error) {
+	ch, err := p.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Put(ch)
+
+	return ch.PublishWithConfirmAndTracing(ctx, exchange, routingKey, mandatory, immediate, msg)
+}
+
+func (p *ChannelPool) Stats() ChannelPoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return ChannelPoolStats{
+		Size:    p.size,
+		Idle:    len(p.idle),
+		InUse:   p.inUse,
+		Waiting: len(p.waiters),
+		Created: p.created,
+	}
+}
+
+func (p *ChannelPool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.size -= len(idle)
+	waiters := p.waiters
+	p.waiters = nil
+	p.mu.Unlock()
+
+	for _, waiter := range waiters {
+		close(waiter)
+	}
+
+	var errs []error
+	for _, ch := range idle {
+		if err := ch.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *ChannelPool) wait(ctx context.Context, waiter chan *Channel) (*Channel, bool, error) {
+	span := trace.SpanFromContext(ctx)
+	start := time.Now()
+
+	p.waitingCounter.Add(ctx, 1)
+	defer p.waitingCounter.Add(ctx, -1)
+
+	select {
+	case ch, ok := <-waiter:
+		span.AddEvent("channel_pool.wait", trace.WithAttributes(
+			attribute.Int64("orb.channel_pool.wait_ms", time.Since(start).Milliseconds()),
+		))
+		if !ok {
+			return nil, false, ErrChannelPoolClosed
+		}
+		return ch, ch == nil, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		removed := false
+		for i, w := range p.waiters {
+			if w == waiter {
+				p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		p.mu.Unlock()
+
+		// The waiter was served concurrently with cancellation; give back
+		// whatever it received so the slot is not leaked.
+		if !removed {
+			if ch, ok := <-waiter; ok {
+				if ch != nil {
+					p.Put(ch)
+				} else {
+					p.mu.Lock()
+					p.size--
+					p.handOffSlotLocked()
+					p.mu.Unlock()
+				}
+			}
+		}
+		span.AddEvent("channel_pool.wait", trace.WithAttributes(
+			attribute.Int64("orb.channel_pool.wait_ms", time.Since(start).Milliseconds()),
+			attribute.Bool("orb.channel_pool.wait_cancelled", true),
+		))
+		return nil, false, ctx.Err()
+	}
+}
+
+func (p *ChannelPool) createBorrowed() (*Channel, error) {
+	ch, err := p.newChannel()
+	if err != nil {
+		p.mu.Lock()
+		p.size--
+		p.handOffSlotLocked()
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.borrowLocked()
+	p.mu.Unlock()
+	return ch, nil
+}
+
+func (p *ChannelPool) newChannel() (*Channel, error) {
+	ch, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.config.ConfirmMode {
+		if err := ch.Confirm(false); err != nil {
+			closeChannel(ch)
+			return nil, fmt.Errorf("failed to put channel into confirm mode: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.created++
+	p.mu.Unlock()
+	p.createdCounter.Add(context.Background(), 1)
+
+	go p.watch(ch, ch.NotifyClose(make(chan *amqp091.Error, 1)))
+
+	return ch, nil
+}
+
+// watch removes a channel from the idle set once the broker closes it and
+// tops the pool back up to its minimum size.
+func (p *ChannelPool) watch(ch *Channel, closes chan *amqp091.Error) {
+	for range closes {
+	}
+
+	p.mu.Lock()
+	removed := false
+	for i, idle := range p.idle {
+		if idle == ch {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.size--
+			removed = true
+			break
+		}
+	}
+	replace := removed && !p.closed && p.size < p.config.MinSize && len(p.waiters) == 0
+	if replace {
+		p.size++
+	} else if removed {
+		p.handOffSlotLocked()
+	}
+	p.mu.Unlock()
+
+	if !replace {
+		return
+	}
+
+	replacement, err := p.newChannel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		p.size--
+		p.handOffSlotLocked()
+		return
+	}
+	if p.closed {
+		p.size--
+		go closeChannel(replacement)
+		return
+	}
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		p.borrowLocked()
+		waiter <- replacement
+		return
+	}
+	p.idle = append(p.idle, replacement)
+}
+
+func (p *ChannelPool) healthy(ch *Channel) bool {
+	return ch.Channel != nil && !ch.IsClosed()
+}
+
+func (p *ChannelPool) borrowLocked() {
+	p.inUse++
+	p.inUseCounter.Add(context.Background(), 1)
+}
+
+// handOffSlotLocked lets the first waiter open a channel in a slot that was
+// just freed by a discarded channel.
+func (p *ChannelPool) handOffSlotLocked() {
+	if p.closed || len(p.waiters) == 0 || p.size >= p.config.MaxSize {
+		return
+	}
+	waiter := p.waiters[0]
+	p.waiters = p.waiters[1:]
+	p.size++
+	waiter <- nil
+}
+
+func closeChannel(ch *Channel) {
+	if ch.Channel != nil && !ch.IsClosed() {
+		_ = ch.Close()
+	}
+}
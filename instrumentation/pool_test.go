@@ -0,0 +1,138 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func newTestChannelPool(t *testing.T, config ChannelPoolConfig) *ChannelPool {
+	t.Helper()
+
+	pool, err := newChannelPool(config, func() (*Channel, error) {
+		return NewDefaultChannel(&amqp091.Channel{}), nil
+	})
+	if err != nil {
+		t.Fatalf("newChannelPool() error = %v", err)
+	}
+	return pool
+}
+
+func TestChannelPoolPrefillsMinSize(t *testing.T) {
+	pool := newTestChannelPool(t, ChannelPoolConfig{MinSize: 2, MaxSize: 4})
+
+	stats := pool.Stats()
+	if stats.Size != 2 || stats.Idle != 2 || stats.Created != 2 {
+		t.Errorf("Stats() = %+v, want size 2, idle 2, created 2", stats)
+	}
+}
+
+func TestChannelPoolRejectsInvalidSizes(t *testing.T) {
+	_, err := newChannelPool(ChannelPoolConfig{MinSize: 3, MaxSize: 2}, nil)
+	if err == nil {
+		t.Error("newChannelPool() should reject min size above max size")
+	}
+}
+
+func TestChannelPoolGetPut(t *testing.T) {
+	pool := newTestChannelPool(t, ChannelPoolConfig{MaxSize: 2})
+	ctx := context.Background()
+
+	first, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	second, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if first == second {
+		t.Error("Get() returned the same channel twice")
+	}
+
+	if stats := pool.Stats(); stats.InUse != 2 || stats.Size != 2 {
+		t.Errorf("Stats() = %+v, want 2 in use", stats)
+	}
+
+	pool.Put(first)
+	reused, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if reused != first {
+		t.Error("Get() should reuse an idle channel")
+	}
+	if stats := pool.Stats(); stats.Created != 2 {
+		t.Errorf("Stats().Created = %d, want 2", stats.Created)
+	}
+}
+
+func TestChannelPoolWaitsForReturnedChannel(t *testing.T) {
+	pool := newTestChannelPool(t, ChannelPoolConfig{MaxSize: 1})
+	ctx := context.Background()
+
+	ch, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	got := make(chan *Channel)
+	go func() {
+		borrowed, _ := pool.Get(ctx)
+		got <- borrowed
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Waiting == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Put(ch)
+
+	select {
+	case borrowed := <-got:
+		if borrowed != ch {
+			t.Error("waiting Get() should receive the returned channel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiting Get() was not served")
+	}
+}
+
+func TestChannelPoolGetHonoursContext(t *testing.T) {
+	pool := newTestChannelPool(t, ChannelPoolConfig{MaxSize: 1})
+
+	if _, err := pool.Get(context.Background()); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := pool.Get(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if stats := pool.Stats(); stats.Waiting != 0 {
+		t.Errorf("Stats().Waiting = %d, want 0 after cancellation", stats.Waiting)
+	}
+}
+
+func TestChannelPoolDiscardsUnusableChannels(t *testing.T) {
+	pool := newTestChannelPool(t, ChannelPoolConfig{MaxSize: 1})
+
+	pool.Put(nil)
+	ch, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+
+	ch.Channel = nil
+	pool.Put(ch)
+
+	if stats := pool.Stats(); stats.Size != 0 || stats.Idle != 0 {
+		t.Errorf("Stats() = %+v, want discarded channel", stats)
+	}
+}
@@ -5,32 +5,83 @@ import (
 
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
+type PropagatorConfig struct {
+	// TextMapPropagator is used instead of the global propagator when set.
+	TextMapPropagator propagation.TextMapPropagator
+	// Baggage enables baggage propagation, promotion and size limits.
+	Baggage *BaggageConfig
+}
+
 type Propagator struct {
+	config PropagatorConfig
 }
 
 func NewPropagator() *Propagator {
-	return &Propagator{}
+	return NewPropagatorWithConfig(PropagatorConfig{})
+}
+
+func NewPropagatorWithConfig(config PropagatorConfig) *Propagator {
+	if config.Baggage != nil {
+		baggageConfig := config.Baggage.withDefaults()
+		config.Baggage = &baggageConfig
+	}
+
+	return &Propagator{
+		config: config,
+	}
 }
 
 func (p *Propagator) InjectToPublishing(ctx context.Context, publishing *amqp091.Publishing) {
 	if publishing.Headers == nil {
 		publishing.Headers = make(amqp091.Table)
 	}
-	internal.InjectContext(ctx, publishing.Headers)
+	p.InjectToHeaders(ctx, publishing.Headers)
 }
 
 func (p *Propagator) ExtractFromDelivery(ctx context.Context, delivery *amqp091.Delivery) context.Context {
-	return internal.ExtractContext(ctx, delivery.Headers)
+	return p.ExtractFromHeaders(ctx, delivery.Headers)
 }
 
 func (p *Propagator) InjectToHeaders(ctx context.Context, headers amqp091.Table) {
-	internal.InjectContext(ctx, headers)
+	if headers == nil {
+		return
+	}
+	if p.config.Baggage != nil {
+		ctx = p.config.Baggage.limit(ctx)
+	}
+
+	p.textMapPropagator().Inject(ctx, internal.HeaderCarrier(headers))
+
+	if p.config.Baggage != nil {
+		if _, ok := headers[baggageHeader]; !ok {
+			propagation.Baggage{}.Inject(ctx, internal.HeaderCarrier(headers))
+		}
+	}
 }
 
 func (p *Propagator) ExtractFromHeaders(ctx context.Context, headers amqp091.Table) context.Context {
-	return internal.ExtractContext(ctx, headers)
+	if headers == nil {
+		return ctx
+	}
+
+	ctx = p.textMapPropagator().Extract(ctx, internal.HeaderCarrier(headers))
+
+	if p.config.Baggage != nil {
+		ctx = propagation.Baggage{}.Extract(ctx, internal.HeaderCarrier(headers))
+		ctx = p.config.Baggage.limit(ctx)
+	}
+	return ctx
+}
+
+func (p *Propagator) textMapPropagator() propagation.TextMapPropagator {
+	if p.config.TextMapPropagator != nil {
+		return p.config.TextMapPropagator
+	}
+	return otel.GetTextMapPropagator()
 }
 
 var DefaultPropagator = NewPropagator()
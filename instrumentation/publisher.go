@@ -2,11 +2,14 @@ package instrumentation
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/startower-observability/orb/internal"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -15,10 +18,37 @@ type PublisherConfig struct {
 	Propagator        *Propagator
 	SpanNameFormatter func(exchange, routingKey string) string
 	AttributeEnricher func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) []trace.SpanStartOption
+	BodyCapture       *BodyCaptureConfig
+	HeaderCapture     *HeaderCaptureConfig
+	Filter            PublishFilter
+	LatencyThreshold  time.Duration
+	OnPublishError    func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, err error)
+	Interceptors      []PublishInterceptor
+	PropagateDeadline bool
+	// FailFastWhenBlocked makes publishes on channels created from a
+	// Connection return a *ConnectionBlockedError instead of hanging while
+	// the broker blocks the connection.
+	FailFastWhenBlocked bool
+	// SemconvMode defaults to the mode selected by
+	// OTEL_SEMCONV_STABILITY_OPT_IN.
+	SemconvMode SemconvMode
+	// RateLimit throttles publishes. Publishes wait for the connection to be
+	// unblocked before taking a token.
+	RateLimit *RateLimitConfig
+	// Compression compresses bodies after the interceptors have run.
+	Compression *CompressionConfig
+	// Encryption encrypts bodies after compression. It disables
+	// BodyCapture so plaintext never reaches span events.
+	Encryption *EncryptionConfig
+	// Signing signs messages as they are sent, after encryption.
+	Signing *SigningConfig
 }
 
 type Publisher struct {
 	config PublisherConfig
+	// conn is set for publishers of channels created from a Connection.
+	conn    *Connection
+	limiter *rateLimiter
 }
 
 func NewPublisher(config PublisherConfig) *Publisher {
@@ -28,13 +58,38 @@ func NewPublisher(config PublisherConfig) *Publisher {
 	if config.Propagator == nil {
 		config.Propagator = DefaultPropagator
 	}
+	config.SemconvMode = config.SemconvMode.Resolve()
 	if config.SpanNameFormatter == nil {
 		config.SpanNameFormatter = defaultPublishSpanName
 	}
 
-	return &Publisher{
+	if config.Compression != nil {
+		compression := *config.Compression
+		if compression.Compressor == nil {
+			compression.Compressor = Gzip
+		}
+		if compression.Threshold <= 0 {
+			compression.Threshold = DefaultCompressionThreshold
+		}
+		config.Compression = &compression
+	}
+
+	if config.Encryption != nil {
+		config.BodyCapture = nil
+	}
+	if config.Signing != nil && len(config.Signing.Properties) == 0 {
+		signing := *config.Signing
+		signing.Properties = DefaultSignedProperties
+		config.Signing = &signing
+	}
+
+	p := &Publisher{
 		config: config,
 	}
+	if config.RateLimit != nil {
+		p.limiter = newRateLimiter(*config.RateLimit)
+	}
+	return p
 }
 
 func NewDefaultPublisher() *Publisher {
@@ -48,75 +103,140 @@ func (p *Publisher) Publish(
 	mandatory, immediate bool,
 	msg amqp091.Publishing,
 ) error {
-	spanName := p.config.SpanNameFormatter(exchange, routingKey)
+	return p.publish(ctx, exchange, routingKey, &msg, func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		return channel.Publish(exchange, routingKey, mandatory, immediate, *msg)
+	})
+}
 
-	spanOpts := []trace.SpanStartOption{
-		trace.WithSpanKind(trace.SpanKindProducer),
-	}
+func (p *Publisher) PublishWithConfirm(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	msg amqp091.Publishing,
+) (*amqp091.DeferredConfirmation, error) {
+	var confirmation *amqp091.DeferredConfirmation
+	err := p.publish(ctx, exchange, routingKey, &msg, func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		release := func() {}
+		if p.limiter != nil {
+			var err error
+			if release, err = p.limiter.acquireUnconfirmed(ctx); err != nil {
+				return err
+			}
+		}
 
-	attrs := internal.GetPublishAttributes(exchange, routingKey, &msg)
-	for _, attr := range attrs {
-		spanOpts = append(spanOpts, trace.WithAttributes(attr))
-	}
+		var err error
+		confirmation, err = channel.PublishWithDeferredConfirmWithContext(
+			ctx, exchange, routingKey, mandatory, immediate, *msg,
+		)
+		if err != nil || confirmation == nil {
+			release()
+			return err
+		}
+		go func() {
+			<-confirmation.Done()
+			release()
+		}()
+		return nil
+	})
 
-	if p.config.AttributeEnricher != nil {
-		customOpts := p.config.AttributeEnricher(ctx, exchange, routingKey, &msg)
-		spanOpts = append(spanOpts, customOpts...)
+	return confirmation, err
+}
+
+func (p *Publisher) publish(
+	ctx context.Context,
+	exchange, routingKey string,
+	msg *amqp091.Publishing,
+	send PublishFunc,
+) error {
+	start := time.Now()
+
+	mode := FilterRecord
+	if p.config.Filter != nil {
+		mode = p.config.Filter(exchange, routingKey, msg)
 	}
 
-	ctx, span := p.config.Tracer.Start(ctx, spanName, spanOpts...)
-	defer span.End()
+	span := nonRecordingSpan()
+	if mode == FilterRecord {
+		ctx, span = p.startSpan(ctx, exchange, routingKey, msg)
+		defer span.End()
+
+		if p.config.BodyCapture != nil {
+			p.config.BodyCapture.record(span, msg.ContentType, msg.Body)
+		}
+	}
 
 	if msg.Headers == nil {
 		msg.Headers = make(amqp091.Table)
 	}
-	p.config.Propagator.InjectToPublishing(ctx, &msg)
+	p.config.Propagator.InjectToPublishing(ctx, msg)
+	if p.config.PropagateDeadline {
+		injectDeadline(ctx, msg.Headers)
+	}
 
-	err := channel.Publish(exchange, routingKey, mandatory, immediate, msg)
+	err := p.checkBlocked(ctx, span)
+	if err == nil {
+		ctx := contextWithSemconvMode(ctx, p.config.SemconvMode)
+		err = p.intercepted(p.outbound(send))(ctx, exchange, routingKey, msg)
+	}
 
 	internal.SafeSetSpanStatus(span, err)
+	if errors.Is(err, ErrPublishVetoed) {
+		span.SetAttributes(attribute.Bool(internal.MessagingOrbPublishVetoed, true))
+	} else if err != nil && p.config.OnPublishError != nil {
+		p.config.OnPublishError(ctx, exchange, routingKey, msg, err)
+	}
+
+	if elapsed := time.Since(start); shouldRecordDeferred(mode, err, elapsed, p.config.LatencyThreshold) {
+		_, deferred := p.startSpan(ctx, exchange, routingKey, msg,
+			trace.WithTimestamp(start), deferredSpanAttributes(elapsed, p.config.LatencyThreshold))
+		if p.config.BodyCapture != nil {
+			p.config.BodyCapture.record(deferred, msg.ContentType, msg.Body)
+		}
+		internal.SafeSetSpanStatus(deferred, err)
+		deferred.End()
+	}
 
 	return err
 }
 
-func (p *Publisher) PublishWithConfirm(
+// outbound wraps send with the body transformations applied after the
+// interceptors.
+func (p *Publisher) outbound(send PublishFunc) PublishFunc {
+	return p.limit(p.compress(p.encrypt(p.sign(send))))
+}
+
+func (p *Publisher) startSpan(
 	ctx context.Context,
-	channel *amqp091.Channel,
 	exchange, routingKey string,
-	mandatory, immediate bool,
-	msg amqp091.Publishing,
-) (*amqp091.DeferredConfirmation, error) {
+	msg *amqp091.Publishing,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
 	spanName := p.config.SpanNameFormatter(exchange, routingKey)
 
 	spanOpts := []trace.SpanStartOption{
 		trace.WithSpanKind(trace.SpanKindProducer),
 	}
 
-	attrs := internal.GetPublishAttributes(exchange, routingKey, &msg)
+	attrs := internal.ConvertAttributes(p.config.SemconvMode, internal.GetPublishAttributes(exchange, routingKey, msg))
+	if p.config.HeaderCapture != nil {
+		attrs = append(attrs, p.config.HeaderCapture.attributes(msg.Headers)...)
+	}
+	attrs = append(attrs, p.config.Propagator.baggageAttributes(ctx)...)
+	attrs = append(attrs, p.conn.spanAttributes()...)
 	for _, attr := range attrs {
 		spanOpts = append(spanOpts, trace.WithAttributes(attr))
 	}
 
 	if p.config.AttributeEnricher != nil {
-		customOpts := p.config.AttributeEnricher(ctx, exchange, routingKey, &msg)
+		customOpts := p.config.AttributeEnricher(ctx, exchange, routingKey, msg)
 		spanOpts = append(spanOpts, customOpts...)
 	}
+	spanOpts = append(spanOpts, spanStartOptionsFromContext(ctx)...)
+	spanOpts = append(spanOpts, opts...)
 
 	ctx, span := p.config.Tracer.Start(ctx, spanName, spanOpts...)
-	defer span.End()
-
-	if msg.Headers == nil {
-		msg.Headers = make(amqp091.Table)
-	}
-	p.config.Propagator.InjectToPublishing(ctx, &msg)
-
-	confirmation, err := channel.PublishWithDeferredConfirmWithContext(
-		ctx, exchange, routingKey, mandatory, immediate, msg,
-	)
-
-	internal.SafeSetSpanStatus(span, err)
-
-	return confirmation, err
+	return withoutSpanStartOptions(ctx), span
 }
 
 func defaultPublishSpanName(exchange, routingKey string) string {
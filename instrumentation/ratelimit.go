@@ -0,0 +1,327 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrRateLimited = errors.New("publish rate limit exceeded")
+
+// RateLimit is a token bucket refilled at Rate messages per second and
+// holding at most Burst tokens. A zero Rate means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type RateLimitConfig struct {
+	// Global limits all publishes of the Publisher.
+	Global RateLimit
+	// PerKey limits each key returned by Key separately.
+	PerKey RateLimit
+	// Key groups publishes for PerKey. Defaults to the exchange and routing
+	// key.
+	Key func(exchange, routingKey string) string
+	// MaxUnconfirmed bounds the messages published with PublishWithConfirm
+	// that the broker has not confirmed yet. Zero means unlimited.
+	MaxUnconfirmed int
+	// FailFast returns ErrRateLimited, or the *ConnectionBlockedError of a
+	// blocked connection, instead of waiting. Waits otherwise end when the
+	// publish context is done.
+	FailFast bool
+	// Meter records wait times. Defaults to the global meter provider.
+	Meter metric.Meter
+}
+
+// minBucketIdle is the shortest time a per-key bucket is kept unused.
+const minBucketIdle = time.Minute
+
+type rateLimiter struct {
+	config RateLimitConfig
+	global *tokenBucket
+	now    func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*tokenBucket
+	// Per-key buckets unused for idle have refilled to their burst and are
+	// dropped, so keys with many distinct values do not grow keys forever.
+	idle  time.Duration
+	swept time.Time
+
+	unconfirmed chan struct{}
+
+	waitTime metric.Float64Histogram
+	rejected metric.Int64Counter
+}
+
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if config.Key == nil {
+		config.Key = func(exchange, routingKey string) string {
+			return exchange + "/" + routingKey
+		}
+	}
+	if config.Meter == nil {
+		config.Meter = otel.Meter(internal.TracerName)
+	}
+
+	l := &rateLimiter{
+		config: config,
+		global: newTokenBucket(config.Global),
+		now:    time.Now,
+		keys:   make(map[string]*tokenBucket),
+		idle:   minBucketIdle,
+	}
+	if config.PerKey.Rate > 0 {
+		refill := time.Duration(float64(max(config.PerKey.Burst, 1)) / config.PerKey.Rate * float64(time.Second))
+		l.idle = max(refill, minBucketIdle)
+	}
+	if config.MaxUnconfirmed > 0 {
+		l.unconfirmed = make(chan struct{}, config.MaxUnconfirmed)
+	}
+	l.waitTime, _ = config.Meter.Float64Histogram(
+		"orb.publisher.rate_limit.wait",
+		metric.WithDescription("Time publishes waited for the rate limiter"),
+		metric.WithUnit("s"),
+	)
+	l.rejected, _ = config.Meter.Int64Counter(
+		"orb.publisher.rate_limit.rejected",
+		metric.WithDescription("Number of publishes rejected by the rate limiter"),
+	)
+	return l
+}
+
+// wait blocks until the connection is unblocked and a token is available for
+// the destination.
+// limit waits for the rate limiter after the interceptors, so publishes they
+// veto take no tokens.
+func (p *Publisher) limit(next PublishFunc) PublishFunc {
+	if p.limiter == nil {
+		return next
+	}
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		if err := p.limiter.wait(ctx, p.conn, exchange, routingKey); err != nil {
+			return err
+		}
+		return next(ctx, exchange, routingKey, msg)
+	}
+}
+
+func (l *rateLimiter) wait(ctx context.Context, conn *Connection, exchange, routingKey string) error {
+	start := time.Now()
+	err := l.waitUnblocked(ctx, conn)
+	if err == nil {
+		err = l.take(ctx, l.bucket(exchange, routingKey))
+	}
+	l.record(ctx, internal.MessagingOrbRateLimitWait, "rate", start, err)
+	return err
+}
+
+func (l *rateLimiter) waitUnblocked(ctx context.Context, conn *Connection) error {
+	// Spooled publishes are written to disk rather than waiting.
+	if conn == nil || isSpooling(ctx) {
+		return nil
+	}
+	if blocked := conn.events.blockedError(); blocked != nil && l.config.FailFast {
+		return blocked
+	}
+	return conn.events.waitUnblocked(ctx)
+}
+
+func (l *rateLimiter) take(ctx context.Context, bucket *tokenBucket) error {
+	if l.config.FailFast {
+		if !bucket.tryTake(l.global) {
+			return ErrRateLimited
+		}
+		return nil
+	}
+
+	delay := max(bucket.reserve(), l.global.reserve())
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.cancel()
+		l.global.cancel()
+		return ctx.Err()
+	}
+}
+
+// acquireUnconfirmed reserves a slot for a message awaiting confirmation.
+// The returned function releases it.
+func (l *rateLimiter) acquireUnconfirmed(ctx context.Context) (func(), error) {
+	if l.unconfirmed == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	var err error
+	select {
+	case l.unconfirmed <- struct{}{}:
+	default:
+		if l.config.FailFast {
+			err = ErrRateLimited
+			break
+		}
+		select {
+		case l.unconfirmed <- struct{}{}:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	l.record(ctx, internal.MessagingOrbUnconfirmedWait, "unconfirmed", start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	return func() { once.Do(func() { <-l.unconfirmed }) }, nil
+}
+
+func (l *rateLimiter) record(ctx context.Context, key, limit string, start time.Time, err error) {
+	waited := time.Since(start)
+	attrs := metric.WithAttributes(attribute.String("limit", limit))
+	l.waitTime.Record(ctx, waited.Seconds(), attrs)
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Float64(key, float64(waited)/float64(time.Millisecond)))
+	if errors.Is(err, ErrRateLimited) {
+		l.rejected.Add(ctx, 1, attrs)
+		span.SetAttributes(attribute.Bool(internal.MessagingOrbRateLimited, true))
+	}
+}
+
+func (l *rateLimiter) bucket(exchange, routingKey string) *tokenBucket {
+	if l.config.PerKey.Rate <= 0 {
+		return newTokenBucket(RateLimit{})
+	}
+
+	key := l.config.Key(exchange, routingKey)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.swept) >= l.idle {
+		l.sweep(now)
+	}
+	bucket, ok := l.keys[key]
+	if !ok {
+		bucket = newTokenBucket(l.config.PerKey)
+		bucket.now = l.now
+		l.keys[key] = bucket
+	}
+	return bucket
+}
+
+// sweep drops the buckets unused for l.idle. l.mu must be held.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range l.keys {
+		if now.Sub(bucket.lastUsed()) >= l.idle {
+			delete(l.keys, key)
+		}
+	}
+	l.swept = now
+}
+
+type tokenBucket struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(max(limit.Burst, 1))
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		now:    time.Now,
+		tokens: burst,
+	}
+}
+
+func (b *tokenBucket) lastUsed() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+func (b *tokenBucket) unlimited() bool {
+	return b.rate <= 0
+}
+
+func (b *tokenBucket) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes a token, possibly going into debt, and returns how long to
+// wait until the token is actually available.
+func (b *tokenBucket) reserve() time.Duration {
+	if b.unlimited() {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token.
+func (b *tokenBucket) cancel() {
+	if b.unlimited() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.burst, b.tokens+1)
+}
+
+// tryTake takes a token from b and other only if both have one available.
+func (b *tokenBucket) tryTake(other *tokenBucket) bool {
+	if !b.take() {
+		return false
+	}
+	if !other.take() {
+		b.cancel()
+		return false
+	}
+	return true
+}
+
+func (b *tokenBucket) take() bool {
+	if b.unlimited() {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
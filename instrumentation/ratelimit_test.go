@@ -0,0 +1,205 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bucket := newTokenBucket(RateLimit{Rate: 10, Burst: 2})
+	bucket.now = func() time.Time { return now }
+
+	if !bucket.take() || !bucket.take() {
+		t.Fatal("burst tokens should be available")
+	}
+	if bucket.take() {
+		t.Fatal("bucket should be empty")
+	}
+	if wait := bucket.reserve(); wait != 100*time.Millisecond {
+		t.Errorf("reserve() = %v, want 100ms", wait)
+	}
+	bucket.cancel()
+
+	now = now.Add(time.Second)
+	if !bucket.take() || !bucket.take() || bucket.take() {
+		t.Error("refill should be capped at the burst")
+	}
+}
+
+func TestRateLimiterEvictsIdleKeys(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := newRateLimiter(RateLimitConfig{PerKey: RateLimit{Rate: 10, Burst: 5}})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 100; i++ {
+		limiter.bucket("orders", fmt.Sprintf("order.%d", i)).take()
+	}
+	active := limiter.bucket("orders", "order.active")
+	active.take()
+	if len(limiter.keys) != 101 {
+		t.Fatalf("keys = %d, want 101", len(limiter.keys))
+	}
+
+	now = now.Add(limiter.idle / 2)
+	active.take()
+	now = now.Add(limiter.idle / 2)
+	limiter.bucket("orders", "order.new")
+
+	if len(limiter.keys) != 2 {
+		t.Errorf("keys = %d, want only the active and new keys", len(limiter.keys))
+	}
+	if limiter.bucket("orders", "order.active") != active {
+		t.Error("recently used bucket should be kept")
+	}
+}
+
+func TestPublisherRateLimitFailFast(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	publisher := NewPublisher(PublisherConfig{
+		Tracer: tracer,
+		RateLimit: &RateLimitConfig{
+			PerKey:   RateLimit{Rate: 0.001, Burst: 1},
+			FailFast: true,
+		},
+	})
+
+	var sent []sentMessage
+	publish := func(routingKey string) error {
+		return publisher.publish(context.Background(), "orders", routingKey, &amqp091.Publishing{}, recordingSend(&sent))
+	}
+
+	if err := publish("order.created"); err != nil {
+		t.Fatalf("first publish error = %v", err)
+	}
+	if err := publish("order.created"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("second publish error = %v, want %v", err, ErrRateLimited)
+	}
+	if err := publish("order.paid"); err != nil {
+		t.Errorf("publish to another key error = %v", err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("sent = %d, want 2", len(sent))
+	}
+
+	limited := recorder.Ended()[1]
+	var marked, waited bool
+	for _, attr := range limited.Attributes() {
+		switch string(attr.Key) {
+		case internal.MessagingOrbRateLimited:
+			marked = attr.Value.AsBool()
+		case internal.MessagingOrbRateLimitWait:
+			waited = true
+		}
+	}
+	if !marked || !waited {
+		t.Errorf("rate limited span attributes = %v", limited.Attributes())
+	}
+}
+
+func TestPublisherRateLimitIgnoresVetoedPublishes(t *testing.T) {
+	publisher := NewPublisher(PublisherConfig{
+		Interceptors: []PublishInterceptor{
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next PublishFunc) error {
+				if len(msg.Body) == 0 {
+					return ErrPublishVetoed
+				}
+				return next(ctx, exchange, routingKey, msg)
+			},
+		},
+		RateLimit: &RateLimitConfig{
+			Global:   RateLimit{Rate: 0.001, Burst: 2},
+			PerKey:   RateLimit{Rate: 0.001, Burst: 1},
+			FailFast: true,
+		},
+	})
+
+	var sent []sentMessage
+	for i := 0; i < 5; i++ {
+		err := publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{}, recordingSend(&sent))
+		if !errors.Is(err, ErrPublishVetoed) {
+			t.Fatalf("vetoed publish error = %v, want %v", err, ErrPublishVetoed)
+		}
+	}
+
+	// The vetoed publishes left the burst intact for both buckets.
+	if err := publisher.publish(context.Background(), "orders", "order.created", &amqp091.Publishing{Body: []byte("a")}, recordingSend(&sent)); err != nil {
+		t.Errorf("publish error = %v, want the per-key burst unused", err)
+	}
+	if err := publisher.publish(context.Background(), "orders", "order.paid", &amqp091.Publishing{Body: []byte("b")}, recordingSend(&sent)); err != nil {
+		t.Errorf("publish error = %v, want the global burst unused", err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("sent = %d, want 2", len(sent))
+	}
+}
+
+func TestPublisherRateLimitWaitsWithContext(t *testing.T) {
+	publisher := NewPublisher(PublisherConfig{
+		RateLimit: &RateLimitConfig{Global: RateLimit{Rate: 0.001, Burst: 1}},
+	})
+
+	var sent []sentMessage
+	_ = publisher.publish(context.Background(), "", "q", &amqp091.Publishing{}, recordingSend(&sent))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := publisher.publish(ctx, "", "q", &amqp091.Publishing{}, recordingSend(&sent))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("publish() error = %v, want deadline exceeded", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("sent = %d, want 1", len(sent))
+	}
+}
+
+func TestPublisherRateLimitWaitsForUnblock(t *testing.T) {
+	conn := NewConnection(nil, ConnectionConfig{})
+	conn.events.handleBlocked(amqp091.Blocking{Active: true, Reason: "memory alarm"})
+
+	publisher := NewPublisher(PublisherConfig{RateLimit: &RateLimitConfig{}})
+	publisher.conn = conn
+
+	var sent []sentMessage
+	done := make(chan error, 1)
+	go func() {
+		done <- publisher.publish(context.Background(), "", "q", &amqp091.Publishing{}, recordingSend(&sent))
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("publish returned while blocked: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	conn.events.handleBlocked(amqp091.Blocking{Active: false})
+	if err := <-done; err != nil {
+		t.Errorf("publish() error = %v", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("sent = %d, want 1", len(sent))
+	}
+}
+
+func TestRateLimiterMaxUnconfirmed(t *testing.T) {
+	limiter := newRateLimiter(RateLimitConfig{MaxUnconfirmed: 1, FailFast: true})
+
+	release, err := limiter.acquireUnconfirmed(context.Background())
+	if err != nil {
+		t.Fatalf("acquireUnconfirmed() error = %v", err)
+	}
+	if _, err := limiter.acquireUnconfirmed(context.Background()); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("acquireUnconfirmed() error = %v, want %v", err, ErrRateLimited)
+	}
+	release()
+	release()
+	if _, err := limiter.acquireUnconfirmed(context.Background()); err != nil {
+		t.Errorf("acquireUnconfirmed() after release error = %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrNoRoute = errors.New("no route matched the delivery")
+
+// RouteMatcher reports whether a delivery belongs to a route.
+type RouteMatcher func(delivery *amqp091.Delivery) bool
+
+// MatchRoutingKey matches routing keys against an AMQP topic pattern, where
+// "*" stands for exactly one word and "#" for zero or more words.
+func MatchRoutingKey(pattern string) RouteMatcher {
+	words := strings.Split(pattern, ".")
+	return func(delivery *amqp091.Delivery) bool {
+		return matchTopic(words, strings.Split(delivery.RoutingKey, "."))
+	}
+}
+
+// MatchType matches the AMQP Type property.
+func MatchType(messageType string) RouteMatcher {
+	return func(delivery *amqp091.Delivery) bool {
+		return delivery.Type == messageType
+	}
+}
+
+// MatchHeaders matches deliveries carrying every header in match. Values are
+// compared by their string form, so int32(1) and int64(1) are equal.
+func MatchHeaders(match amqp091.Table) RouteMatcher {
+	return func(delivery *amqp091.Delivery) bool {
+		for name, want := range match {
+			got, ok := delivery.Headers[name]
+			if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type RouterConfig struct {
+	// Fallback handles deliveries no route matches. When nil they are
+	// dead-lettered with ErrNoRoute.
+	Fallback MessageHandler
+}
+
+type route struct {
+	name    string
+	match   RouteMatcher
+	handler MessageHandler
+}
+
+// Router dispatches deliveries to the first registered route that matches.
+// The consumer span is renamed after the route so span names stay low
+// cardinality. Routes must be registered before the router is used.
+type Router struct {
+	config RouterConfig
+	routes []route
+}
+
+func NewRouter(config RouterConfig) *Router {
+	return &Router{
+		config: config,
+	}
+}
+
+// Route registers a handler for routing keys matching an AMQP topic pattern.
+func (r *Router) Route(pattern string, handler MessageHandler) *Router {
+	return r.Add(pattern, MatchRoutingKey(pattern), handler)
+}
+
+// RouteType registers a handler for a message Type.
+func (r *Router) RouteType(messageType string, handler MessageHandler) *Router {
+	return r.Add(messageType, MatchType(messageType), handler)
+}
+
+func (r *Router) RouteHeaders(name string, match amqp091.Table, handler MessageHandler) *Router {
+	return r.Add(name, MatchHeaders(match), handler)
+}
+
+// Add registers a handler under name for deliveries accepted by match.
+func (r *Router) Add(name string, match RouteMatcher, handler MessageHandler) *Router {
+	r.routes = append(r.routes, route{name: name, match: match, handler: handler})
+	return r
+}
+
+// HandleDelivery is a MessageHandler that dispatches the delivery.
+func (r *Router) HandleDelivery(ctx context.Context, delivery amqp091.Delivery) error {
+	span := trace.SpanFromContext(ctx)
+
+	for _, rt := range r.routes {
+		if !rt.match(&delivery) {
+			continue
+		}
+		span.SetName(fmt.Sprintf("%s process", rt.name))
+		span.SetAttributes(attribute.String(internal.MessagingOrbRoute, rt.name))
+		return rt.handler(ctx, delivery)
+	}
+
+	if r.config.Fallback != nil {
+		return r.config.Fallback(ctx, delivery)
+	}
+	return DeadLetter(ErrNoRoute)
+}
+
+func matchTopic(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchTopic(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+	}
+}
@@ -0,0 +1,120 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestMatchRoutingKey(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		routingKey string
+		want       bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.updated", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order.created.eu", false},
+		{"*.created", "order.created", true},
+		{"order.#", "order", true},
+		{"order.#", "order.created.eu", true},
+		{"#.eu", "order.created.eu", true},
+		{"#.eu", "order.created.us", false},
+		{"order.#.eu", "order.eu", true},
+		{"#", "anything.at.all", true},
+	}
+
+	for _, tt := range tests {
+		got := MatchRoutingKey(tt.pattern)(&amqp091.Delivery{RoutingKey: tt.routingKey})
+		if got != tt.want {
+			t.Errorf("MatchRoutingKey(%q)(%q) = %v, want %v", tt.pattern, tt.routingKey, got, tt.want)
+		}
+	}
+}
+
+func TestMatchHeaders(t *testing.T) {
+	match := MatchHeaders(amqp091.Table{"region": "eu", "version": int32(2)})
+
+	if !match(&amqp091.Delivery{Headers: amqp091.Table{"region": "eu", "version": int64(2), "other": "x"}}) {
+		t.Error("MatchHeaders should match when every header is present")
+	}
+	if match(&amqp091.Delivery{Headers: amqp091.Table{"region": "eu"}}) {
+		t.Error("MatchHeaders should not match when a header is missing")
+	}
+}
+
+func TestRouterDispatch(t *testing.T) {
+	var got string
+	record := func(name string) MessageHandler {
+		return func(ctx context.Context, delivery amqp091.Delivery) error {
+			got = name
+			return nil
+		}
+	}
+
+	router := NewRouter(RouterConfig{Fallback: record("fallback")}).
+		RouteType("orders.v1.refunded", record("type")).
+		RouteHeaders("eu", amqp091.Table{"region": "eu"}, record("headers")).
+		Route("order.*", record("pattern"))
+
+	tests := []struct {
+		name     string
+		delivery amqp091.Delivery
+		want     string
+	}{
+		{"type", amqp091.Delivery{RoutingKey: "order.created", Type: "orders.v1.refunded"}, "type"},
+		{"headers", amqp091.Delivery{RoutingKey: "order.created", Headers: amqp091.Table{"region": "eu"}}, "headers"},
+		{"pattern", amqp091.Delivery{RoutingKey: "order.created"}, "pattern"},
+		{"fallback", amqp091.Delivery{RoutingKey: "invoice.created"}, "fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = ""
+			if err := router.HandleDelivery(context.Background(), tt.delivery); err != nil {
+				t.Fatalf("HandleDelivery() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("dispatched to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouterNamesSpanAfterRoute(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	consumer := NewConsumer(ConsumerConfig{Tracer: tracer})
+	router := NewRouter(RouterConfig{}).Route("order.#", func(ctx context.Context, delivery amqp091.Delivery) error {
+		return nil
+	})
+
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, RoutingKey: "order.created.eu"}, router.HandleDelivery)
+
+	span := recorder.Ended()[0]
+	if span.Name() != "order.# process" {
+		t.Errorf("span name = %q, want %q", span.Name(), "order.# process")
+	}
+	found := false
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == internal.MessagingOrbRoute && attr.Value.AsString() == "order.#" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("span should record the matched route")
+	}
+}
+
+func TestRouterDeadLettersUnmatched(t *testing.T) {
+	router := NewRouter(RouterConfig{})
+
+	err := router.HandleDelivery(context.Background(), amqp091.Delivery{RoutingKey: "order.created"})
+	if !errors.Is(err, ErrNoRoute) || !IsDeadLetter(err) {
+		t.Errorf("HandleDelivery() error = %v, want dead-lettered %v", err, ErrNoRoute)
+	}
+}
@@ -0,0 +1,14 @@
+package instrumentation
+
+import "github.com/startower-observability/orb/internal"
+
+// SemconvMode selects between the messaging attributes this library has
+// always emitted and the stable OpenTelemetry messaging conventions.
+type SemconvMode = internal.SemconvMode
+
+const (
+	SemconvDefault = internal.SemconvDefault
+	SemconvOld     = internal.SemconvOld
+	SemconvStable  = internal.SemconvStable
+	SemconvDup     = internal.SemconvDup
+)
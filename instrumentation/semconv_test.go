@@ -0,0 +1,69 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestSemconvModeSpanAttributes(t *testing.T) {
+	tests := []struct {
+		mode       SemconvMode
+		wantOld    bool
+		wantStable bool
+	}{
+		{mode: SemconvOld, wantOld: true},
+		{mode: SemconvStable, wantStable: true},
+		{mode: SemconvDup, wantOld: true, wantStable: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			tracer, recorder := newTestTracer()
+			publisher := NewPublisher(PublisherConfig{Tracer: tracer, SemconvMode: tt.mode})
+			consumer := NewConsumer(ConsumerConfig{Tracer: tracer, SemconvMode: tt.mode})
+
+			msg := amqp091.Publishing{MessageId: "msg-1"}
+			var sent []sentMessage
+			if err := publisher.publish(context.Background(), "orders", "order.created", &msg, recordingSend(&sent)); err != nil {
+				t.Fatalf("publish() error = %v", err)
+			}
+			_ = consumer.ProcessDelivery(context.Background(), "orders.created",
+				amqp091.Delivery{Acknowledger: &fakeAcknowledger{}, MessageId: "msg-1", RoutingKey: "order.created"},
+				func(ctx context.Context, delivery amqp091.Delivery) error { return nil })
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("ended %d spans, want publish and consume", len(spans))
+			}
+			for _, span := range spans {
+				attrs := make(map[string]interface{})
+				for _, attr := range span.Attributes() {
+					attrs[string(attr.Key)] = attr.Value.AsInterface()
+				}
+				if _, ok := attrs[internal.MessagingDestination]; ok != tt.wantOld {
+					t.Errorf("%s: %s present = %v, want %v", span.Name(), internal.MessagingDestination, ok, tt.wantOld)
+				}
+				if _, ok := attrs[internal.MessagingDestinationName]; ok != tt.wantStable {
+					t.Errorf("%s: %s present = %v, want %v", span.Name(), internal.MessagingDestinationName, ok, tt.wantStable)
+				}
+				if _, ok := attrs[internal.MessagingMessageIDStable]; ok != tt.wantStable {
+					t.Errorf("%s: %s present = %v, want %v", span.Name(), internal.MessagingMessageIDStable, ok, tt.wantStable)
+				}
+			}
+		})
+	}
+}
+
+func TestSemconvModeFromEnvironment(t *testing.T) {
+	t.Setenv(internal.SemconvStabilityOptInEnv, "messaging/dup")
+	if mode := NewPublisher(PublisherConfig{}).config.SemconvMode; mode != SemconvDup {
+		t.Errorf("SemconvMode = %v, want %v", mode, SemconvDup)
+	}
+
+	if mode := NewConsumer(ConsumerConfig{SemconvMode: SemconvOld}).config.SemconvMode; mode != SemconvOld {
+		t.Errorf("SemconvMode = %v, want an explicit mode to take precedence", mode)
+	}
+}
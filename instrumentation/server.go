@@ -0,0 +1,43 @@
+package instrumentation
+
+import (
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// connectionAttributes combines the dial URI with the live connection's
+// addresses and server properties. Without a URI the server address falls
+// back to the remote address.
+func connectionAttributes(uri *amqp091.URI, conn *amqp091.Connection, recordUser bool) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if uri != nil {
+		attrs = append(attrs, internal.URIAttributes(*uri)...)
+		if recordUser {
+			attrs = append(attrs, internal.UserAttributes(*uri)...)
+		}
+	}
+	if conn == nil {
+		return attrs
+	}
+
+	if uri == nil {
+		attrs = append(attrs, internal.ServerAddrAttributes(conn.RemoteAddr())...)
+	}
+	attrs = append(attrs, internal.AddrAttributes(conn.RemoteAddr(), conn.LocalAddr())...)
+	attrs = append(attrs, internal.ServerPropertyAttributes(conn.Properties)...)
+	return attrs
+}
+
+// Attributes returns the server and network attributes recorded on spans of
+// channels created from the connection.
+func (c *Connection) Attributes() []attribute.KeyValue {
+	return append([]attribute.KeyValue(nil), c.attributes...)
+}
+
+func (c *Connection) spanAttributes() []attribute.KeyValue {
+	if c == nil {
+		return nil
+	}
+	return c.attributes
+}
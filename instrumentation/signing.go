@@ -0,0 +1,328 @@
+package instrumentation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrUnsigned         = errors.New("message is not signed")
+	ErrInvalidSignature = errors.New("message signature is invalid")
+)
+
+const (
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmEd25519    = "ed25519"
+)
+
+// Message properties that can be covered by a signature in addition to the
+// body.
+const (
+	PropertyExchange        = "exchange"
+	PropertyRoutingKey      = "routing-key"
+	PropertyContentType     = "content-type"
+	PropertyContentEncoding = "content-encoding"
+	PropertyMessageID       = "message-id"
+	PropertyCorrelationID   = "correlation-id"
+	PropertyType            = "type"
+	PropertyTimestamp       = "timestamp"
+	PropertyAppID           = "app-id"
+	PropertyUserID          = "user-id"
+)
+
+// DefaultSignedProperties are signed when SigningConfig.Properties is empty.
+// The routing key and exchange are left out because dead-lettering and
+// shovels change them.
+var DefaultSignedProperties = []string{
+	PropertyContentType,
+	PropertyContentEncoding,
+	PropertyMessageID,
+	PropertyType,
+	PropertyTimestamp,
+}
+
+// Signer signs messages with a key identified by KeyID.
+type Signer interface {
+	Algorithm() string
+	KeyID() string
+	Sign(data []byte) ([]byte, error)
+}
+
+// Verifier checks signatures made with one key.
+type Verifier interface {
+	Verify(data, signature []byte) error
+}
+
+// KeyResolver returns the Verifier for the key a message claims to be signed
+// with. It must fail when algorithm is not the one the key is meant for, so a
+// public Ed25519 key is never used as an HMAC secret.
+type KeyResolver func(ctx context.Context, algorithm, keyID string) (Verifier, error)
+
+type hmacKey struct {
+	keyID string
+	key   []byte
+}
+
+// NewHMACSigner returns an HMAC-SHA256 Signer. The returned value is also
+// the matching Verifier.
+func NewHMACSigner(keyID string, key []byte) Signer {
+	return hmacKey{keyID: keyID, key: key}
+}
+
+func NewHMACVerifier(key []byte) Verifier {
+	return hmacKey{key: key}
+}
+
+func (k hmacKey) Algorithm() string { return AlgorithmHMACSHA256 }
+
+func (k hmacKey) KeyID() string { return k.keyID }
+
+func (k hmacKey) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (k hmacKey) Verify(data, signature []byte) error {
+	expected, _ := k.Sign(data)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+type ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) Signer {
+	return ed25519Signer{keyID: keyID, key: key}
+}
+
+func (s ed25519Signer) Algorithm() string { return AlgorithmEd25519 }
+
+func (s ed25519Signer) KeyID() string { return s.keyID }
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	return ed25519.Sign(s.key, data), nil
+}
+
+type ed25519Verifier ed25519.PublicKey
+
+func NewEd25519Verifier(key ed25519.PublicKey) Verifier {
+	return ed25519Verifier(key)
+}
+
+func (v ed25519Verifier) Verify(data, signature []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(v), data, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SigningConfig signs published messages. The signature, key ID, algorithm
+// and list of signed properties are added as headers.
+type SigningConfig struct {
+	Signer Signer
+	// Properties are signed along with the body. Defaults to
+	// DefaultSignedProperties.
+	Properties []string
+}
+
+// VerificationAction decides what happens to messages that are unsigned or
+// fail verification.
+type VerificationAction int
+
+const (
+	// VerificationDeadLetter nacks the message without requeueing it.
+	VerificationDeadLetter VerificationAction = iota
+	// VerificationDrop acknowledges and discards the message.
+	VerificationDrop
+)
+
+type VerificationConfig struct {
+	Resolver KeyResolver
+	// AllowUnsigned passes unsigned messages to the handler.
+	AllowUnsigned bool
+	// RequiredProperties must be covered by the signature.
+	RequiredProperties []string
+	Action             VerificationAction
+}
+
+func (p *Publisher) sign(next PublishFunc) PublishFunc {
+	config := p.config.Signing
+	if config == nil {
+		return next
+	}
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		signed := *msg
+		signed.Headers = maps.Clone(msg.Headers)
+		if signed.Headers == nil {
+			signed.Headers = make(amqp091.Table)
+		}
+
+		props := messageProperties{
+			exchange: exchange, routingKey: routingKey,
+			contentType: msg.ContentType, contentEncoding: msg.ContentEncoding,
+			messageID: msg.MessageId, correlationID: msg.CorrelationId, messageType: msg.Type,
+			timestamp: msg.Timestamp.Unix(), appID: msg.AppId, userID: msg.UserId,
+		}
+		data, err := signingInput(config.Properties, props, msg.Body)
+		if err != nil {
+			return fmt.Errorf("failed to sign message: %w", err)
+		}
+		signature, err := config.Signer.Sign(data)
+		if err != nil {
+			return fmt.Errorf("failed to sign message: %w", err)
+		}
+
+		signed.Headers[internal.SignatureHeader] = base64.StdEncoding.EncodeToString(signature)
+		signed.Headers[internal.SignatureKeyIDHeader] = config.Signer.KeyID()
+		signed.Headers[internal.SignatureAlgorithmHeader] = config.Signer.Algorithm()
+		signed.Headers[internal.SignedPropertiesHeader] = strings.Join(config.Properties, ",")
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String(internal.MessagingOrbSignatureKeyID, config.Signer.KeyID()))
+		return next(ctx, exchange, routingKey, &signed)
+	}
+}
+
+// verify checks the signature of deliveries before next runs.
+func (c *Consumer) verify(next MessageHandler) MessageHandler {
+	config := c.config.Verification
+	if config == nil {
+		return next
+	}
+	return func(ctx context.Context, delivery amqp091.Delivery) error {
+		span := trace.SpanFromContext(ctx)
+		result, err := verifyDelivery(ctx, config, &delivery)
+		span.SetAttributes(attribute.String(internal.MessagingOrbSignatureResult, result))
+		if keyID, ok := delivery.Headers[internal.SignatureKeyIDHeader].(string); ok {
+			span.SetAttributes(attribute.String(internal.MessagingOrbSignatureKeyID, keyID))
+		}
+		if err == nil {
+			return next(ctx, delivery)
+		}
+
+		span.AddEvent("signature.rejected", trace.WithAttributes(
+			attribute.String("exception.message", err.Error()),
+		))
+		if config.Action == VerificationDrop {
+			return nil
+		}
+		return DeadLetter(err)
+	}
+}
+
+// verifyDelivery returns the verification result recorded on the span:
+// valid, unsigned or invalid.
+func verifyDelivery(ctx context.Context, config *VerificationConfig, delivery *amqp091.Delivery) (string, error) {
+	encoded, ok := delivery.Headers[internal.SignatureHeader].(string)
+	if !ok {
+		if config.AllowUnsigned {
+			return "unsigned", nil
+		}
+		return "unsigned", ErrUnsigned
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "invalid", fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	algorithm, _ := delivery.Headers[internal.SignatureAlgorithmHeader].(string)
+	keyID, _ := delivery.Headers[internal.SignatureKeyIDHeader].(string)
+	signedList, _ := delivery.Headers[internal.SignedPropertiesHeader].(string)
+
+	var properties []string
+	if signedList != "" {
+		properties = strings.Split(signedList, ",")
+	}
+	for _, required := range config.RequiredProperties {
+		if !slices.Contains(properties, required) {
+			return "invalid", fmt.Errorf("%w: property %s is not signed", ErrInvalidSignature, required)
+		}
+	}
+
+	verifier, err := config.Resolver(ctx, algorithm, keyID)
+	if err != nil {
+		return "invalid", fmt.Errorf("%w: failed to resolve key %q: %w", ErrInvalidSignature, keyID, err)
+	}
+
+	props := messageProperties{
+		exchange: delivery.Exchange, routingKey: delivery.RoutingKey,
+		contentType: delivery.ContentType, contentEncoding: delivery.ContentEncoding,
+		messageID: delivery.MessageId, correlationID: delivery.CorrelationId, messageType: delivery.Type,
+		timestamp: delivery.Timestamp.Unix(), appID: delivery.AppId, userID: delivery.UserId,
+	}
+	data, err := signingInput(properties, props, delivery.Body)
+	if err != nil {
+		return "invalid", fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	if err := verifier.Verify(data, signature); err != nil {
+		return "invalid", err
+	}
+	return "valid", nil
+}
+
+type messageProperties struct {
+	exchange, routingKey         string
+	contentType, contentEncoding string
+	messageID, correlationID     string
+	messageType                  string
+	timestamp                    int64
+	appID, userID                string
+}
+
+// signingInput builds the signed bytes: one "name=value" line per property,
+// in the given order, followed by an empty line and the body.
+func signingInput(properties []string, props messageProperties, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, name := range properties {
+		var value string
+		switch name {
+		case PropertyExchange:
+			value = props.exchange
+		case PropertyRoutingKey:
+			value = props.routingKey
+		case PropertyContentType:
+			value = props.contentType
+		case PropertyContentEncoding:
+			value = props.contentEncoding
+		case PropertyMessageID:
+			value = props.messageID
+		case PropertyCorrelationID:
+			value = props.correlationID
+		case PropertyType:
+			value = props.messageType
+		case PropertyTimestamp:
+			value = strconv.FormatInt(props.timestamp, 10)
+		case PropertyAppID:
+			value = props.appID
+		case PropertyUserID:
+			value = props.userID
+		default:
+			return nil, fmt.Errorf("unknown message property %q", name)
+		}
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		buf.WriteString(strconv.Quote(value))
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
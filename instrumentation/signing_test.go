@@ -0,0 +1,140 @@
+package instrumentation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func signedDelivery(t *testing.T, config PublisherConfig, msg amqp091.Publishing) amqp091.Delivery {
+	t.Helper()
+	var sent []sentMessage
+	if err := NewPublisher(config).publish(context.Background(), "orders", "order.created", &msg, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	m := sent[0].msg
+	return amqp091.Delivery{
+		Acknowledger:    &fakeAcknowledger{},
+		Headers:         m.Headers,
+		ContentType:     m.ContentType,
+		ContentEncoding: m.ContentEncoding,
+		MessageId:       m.MessageId,
+		Timestamp:       m.Timestamp,
+		Type:            m.Type,
+		Exchange:        "orders",
+		RoutingKey:      "order.created",
+		Body:            m.Body,
+	}
+}
+
+func verifyResult(t *testing.T, config VerificationConfig, delivery amqp091.Delivery) (bool, *fakeAcknowledger, string) {
+	t.Helper()
+	tracer, recorder := newTestTracer()
+	ack := &fakeAcknowledger{}
+	delivery.Acknowledger = ack
+
+	called := false
+	consumer := NewConsumer(ConsumerConfig{Tracer: tracer, Verification: &config})
+	_ = consumer.ProcessDelivery(context.Background(), "orders", delivery, func(ctx context.Context, delivery amqp091.Delivery) error {
+		called = true
+		return nil
+	})
+
+	var result string
+	for _, attr := range recorder.Ended()[0].Attributes() {
+		if string(attr.Key) == internal.MessagingOrbSignatureResult {
+			result = attr.Value.AsString()
+		}
+	}
+	return called, ack, result
+}
+
+func TestHMACSignatureVerification(t *testing.T) {
+	key := []byte("shared-secret")
+	delivery := signedDelivery(t, PublisherConfig{
+		Signing: &SigningConfig{Signer: NewHMACSigner("team-a", key)},
+	}, amqp091.Publishing{ContentType: "application/json", MessageId: "m-1", Timestamp: time.Unix(1700000000, 0), Body: []byte(`{"cmd":"ship"}`)})
+
+	resolved := ""
+	config := VerificationConfig{Resolver: func(ctx context.Context, algorithm, keyID string) (Verifier, error) {
+		resolved = algorithm + "/" + keyID
+		return NewHMACVerifier(key), nil
+	}}
+
+	called, _, result := verifyResult(t, config, delivery)
+	if !called || result != "valid" {
+		t.Errorf("valid message: called = %v, result = %q", called, result)
+	}
+	if resolved != "hmac-sha256/team-a" {
+		t.Errorf("resolver called with %q", resolved)
+	}
+
+	tampered := delivery
+	tampered.Body = []byte(`{"cmd":"drop"}`)
+	called, ack, result := verifyResult(t, config, tampered)
+	if called || result != "invalid" || ack.nacked != 1 || ack.requeue {
+		t.Errorf("tampered body: called = %v, result = %q, nacked = %d", called, result, ack.nacked)
+	}
+
+	tampered = delivery
+	tampered.MessageId = "m-2"
+	if called, _, _ := verifyResult(t, config, tampered); called {
+		t.Error("tampered signed property should be rejected")
+	}
+
+	tampered = delivery
+	tampered.RoutingKey = "other"
+	if called, _, _ := verifyResult(t, config, tampered); !called {
+		t.Error("unsigned property changes should be accepted")
+	}
+}
+
+func TestEd25519SignatureVerification(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	delivery := signedDelivery(t, PublisherConfig{
+		Signing: &SigningConfig{
+			Signer:     NewEd25519Signer("team-b", private),
+			Properties: []string{PropertyRoutingKey, PropertyType},
+		},
+		Compression: &CompressionConfig{Threshold: 1},
+	}, amqp091.Publishing{Type: "ship", Body: []byte("payload")})
+
+	config := VerificationConfig{
+		Resolver: func(ctx context.Context, algorithm, keyID string) (Verifier, error) {
+			if algorithm != AlgorithmEd25519 || keyID != "team-b" {
+				return nil, errors.New("unknown key")
+			}
+			return NewEd25519Verifier(public), nil
+		},
+		RequiredProperties: []string{PropertyType},
+	}
+	if called, _, result := verifyResult(t, config, delivery); !called || result != "valid" {
+		t.Errorf("compressed signed message: called = %v, result = %q", called, result)
+	}
+
+	config.RequiredProperties = []string{PropertyMessageID}
+	if called, _, _ := verifyResult(t, config, delivery); called {
+		t.Error("message missing a required signed property should be rejected")
+	}
+}
+
+func TestUnsignedMessages(t *testing.T) {
+	resolver := func(ctx context.Context, algorithm, keyID string) (Verifier, error) {
+		return nil, errors.New("unexpected")
+	}
+	delivery := amqp091.Delivery{Body: []byte("hi")}
+
+	called, ack, result := verifyResult(t, VerificationConfig{Resolver: resolver, Action: VerificationDrop}, delivery)
+	if called || result != "unsigned" || ack.acked != 1 {
+		t.Errorf("dropped unsigned message: called = %v, result = %q, acked = %d", called, result, ack.acked)
+	}
+
+	if called, _, _ := verifyResult(t, VerificationConfig{Resolver: resolver, AllowUnsigned: true}, delivery); !called {
+		t.Error("AllowUnsigned should pass unsigned messages to the handler")
+	}
+}
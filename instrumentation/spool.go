@@ -0,0 +1,474 @@
+package instrumentation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrSpoolFull = errors.New("publish spool is full")
+	// ErrReplayNacked is returned by Replay when the broker nacks a replayed
+	// message. The message stays in the spool.
+	ErrReplayNacked = errors.New("spooled message was nacked by the broker")
+)
+
+const (
+	DefaultSpoolMaxBytes       = 64 << 20
+	DefaultSpoolReplayInterval = time.Second
+)
+
+type SpoolConfig struct {
+	// Path is the spool file. It is created if it does not exist.
+	Path string
+	// MaxBytes bounds the spool file. Publishes that would exceed it fail
+	// with ErrSpoolFull. Defaults to 64 MiB.
+	MaxBytes int64
+	// ReplayInterval is how often Run tries to replay. Defaults to 1s.
+	ReplayInterval time.Duration
+	// Meter records spool depth and throughput. Defaults to the global
+	// meter provider.
+	Meter metric.Meter
+}
+
+// SpoolPublisher publishes through a Publisher and writes messages that
+// cannot be published, because there is no channel, the publish fails or the
+// connection is blocked, to a local append-only file. Spooled messages keep
+// their propagated headers and are replayed in order; while the spool is not
+// empty new messages are spooled behind them. Publish returns nil once a
+// message is spooled, and its span is marked with messaging.orb.spooled.
+type SpoolPublisher struct {
+	publisher *Publisher
+	config    SpoolConfig
+
+	// replayMu serialises replays. mu guards the file and is never held
+	// while publishing, so spooling and metrics do not wait on the broker.
+	replayMu sync.Mutex
+	mu       sync.Mutex
+	file     *os.File
+	depth    int64
+	size     int64
+
+	spooled  metric.Int64Counter
+	replayed metric.Int64Counter
+	expired  metric.Int64Counter
+}
+
+type spoolRecord struct {
+	Exchange   string             `json:"exchange"`
+	RoutingKey string             `json:"routing_key"`
+	Mandatory  bool               `json:"mandatory,omitempty"`
+	Immediate  bool               `json:"immediate,omitempty"`
+	Publishing amqp091.Publishing `json:"publishing"`
+}
+
+func NewSpoolPublisher(publisher *Publisher, config SpoolConfig) (*SpoolPublisher, error) {
+	if publisher == nil {
+		publisher = NewDefaultPublisher()
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if config.ReplayInterval <= 0 {
+		config.ReplayInterval = DefaultSpoolReplayInterval
+	}
+	if config.Meter == nil {
+		config.Meter = otel.Meter(internal.TracerName)
+	}
+
+	s := &SpoolPublisher{
+		publisher: publisher,
+		config:    config,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	s.spooled, _ = config.Meter.Int64Counter(
+		"orb.spool.spooled",
+		metric.WithDescription("Number of messages written to the publish spool"),
+	)
+	s.replayed, _ = config.Meter.Int64Counter(
+		"orb.spool.replayed",
+		metric.WithDescription("Number of spooled messages replayed to the broker"),
+	)
+	s.expired, _ = config.Meter.Int64Counter(
+		"orb.spool.expired",
+		metric.WithDescription("Number of spooled messages dropped because their deadline passed before replay"),
+	)
+	depth, _ := config.Meter.Int64ObservableGauge(
+		"orb.spool.depth",
+		metric.WithDescription("Number of messages waiting in the publish spool"),
+	)
+	size, _ := config.Meter.Int64ObservableGauge(
+		"orb.spool.size",
+		metric.WithDescription("Size of the publish spool file"),
+		metric.WithUnit("By"),
+	)
+	_, _ = config.Meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		attrs := metric.WithAttributes(attribute.String("orb.spool.path", config.Path))
+		o.ObserveInt64(depth, s.depth, attrs)
+		o.ObserveInt64(size, s.size, attrs)
+		return nil
+	}, depth, size)
+
+	return s, nil
+}
+
+// Depth returns the number of spooled messages.
+func (s *SpoolPublisher) Depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.depth)
+}
+
+// Publish publishes msg on channel, or spools it. channel may be nil while
+// the broker is unreachable.
+func (s *SpoolPublisher) Publish(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	msg amqp091.Publishing,
+) error {
+	var send PublishFunc
+	if channel != nil {
+		send = func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+			return channel.PublishWithContext(ctx, exchange, routingKey, mandatory, immediate, *msg)
+		}
+	}
+	return s.publish(ctx, exchange, routingKey, mandatory, immediate, &msg, send)
+}
+
+func (s *SpoolPublisher) publish(
+	ctx context.Context,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	msg *amqp091.Publishing,
+	send PublishFunc,
+) error {
+	ctx = context.WithValue(ctx, spoolingKey{}, true)
+	return s.publisher.publish(ctx, exchange, routingKey, msg, func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		record := &spoolRecord{
+			Exchange:   exchange,
+			RoutingKey: routingKey,
+			Mandatory:  mandatory,
+			Immediate:  immediate,
+			Publishing: *msg,
+		}
+		if send == nil || s.Depth() > 0 || s.blocked() {
+			return s.spool(ctx, record, nil)
+		}
+		if err := send(ctx, exchange, routingKey, msg); err != nil {
+			return s.spool(ctx, record, err)
+		}
+		return nil
+	})
+}
+
+func (s *SpoolPublisher) spool(ctx context.Context, record *spoolRecord, cause error) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode spooled message: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return errors.Join(cause, os.ErrClosed)
+	}
+	if s.size+int64(len(line)) > s.config.MaxBytes {
+		return errors.Join(cause, ErrSpoolFull)
+	}
+	if _, err := s.file.Write(line); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to write spool: %w", err))
+	}
+	if err := s.file.Sync(); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to sync spool: %w", err))
+	}
+	s.depth++
+	s.size += int64(len(line))
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Bool(internal.MessagingOrbSpooled, true))
+	if cause != nil {
+		span.AddEvent("spool.write", trace.WithAttributes(attribute.String("exception.message", cause.Error())))
+	}
+	s.spooled.Add(ctx, 1)
+	return nil
+}
+
+// Replay puts channel in confirm mode and publishes spooled messages on it
+// in order, waiting for each to be confirmed before it is removed from the
+// spool. It stops at the first failure or nack and returns how many were
+// replayed. Messages whose publisher deadline has passed are dropped rather
+// than replayed. Each replayed publish gets a new span linked to the span of the
+// original publish.
+func (s *SpoolPublisher) Replay(ctx context.Context, channel *amqp091.Channel) (int, error) {
+	if err := channel.Confirm(false); err != nil {
+		return 0, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return s.replay(ctx, func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+		confirmation, err := channel.PublishWithDeferredConfirmWithContext(
+			ctx, record.Exchange, record.RoutingKey, record.Mandatory, record.Immediate, record.Publishing,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return confirmation.WaitContext, nil
+	})
+}
+
+// replaySend publishes a spooled record and returns a function that waits
+// for the broker to confirm it.
+type replaySend func(ctx context.Context, record *spoolRecord) (confirmFunc, error)
+
+// confirmFunc reports whether the broker acked a published message.
+type confirmFunc func(ctx context.Context) (bool, error)
+
+// Run replays the spool every ReplayInterval with a channel from channel,
+// until ctx is cancelled. Nothing is replayed while the connection is
+// blocked.
+func (s *SpoolPublisher) Run(ctx context.Context, channel func(ctx context.Context) (*amqp091.Channel, error)) error {
+	ticker := time.NewTicker(s.config.ReplayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		if s.Depth() == 0 || s.blocked() {
+			continue
+		}
+		if ch, err := channel(ctx); err == nil && ch != nil {
+			_, _ = s.Replay(ctx, ch)
+		}
+	}
+}
+
+func (s *SpoolPublisher) replay(ctx context.Context, send replaySend) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	records, err := s.snapshot()
+	if err != nil {
+		return 0, err
+	}
+
+	replayed, expired := 0, 0
+	var replayErr error
+	for _, record := range records {
+		// Consumers would reject a message whose publisher deadline has
+		// passed, so it is dropped here and counted instead.
+		if deadline, ok := internal.MessageDeadline(record.Publishing.Headers); ok && !time.Now().Before(deadline) {
+			expired++
+			continue
+		}
+		if replayErr = s.replayRecord(ctx, record, send); replayErr != nil {
+			break
+		}
+		replayed++
+	}
+	s.replayed.Add(ctx, int64(replayed))
+	s.expired.Add(ctx, int64(expired))
+
+	if done := replayed + expired; done > 0 {
+		if err := s.truncate(done); err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, replayErr
+}
+
+func (s *SpoolPublisher) snapshot() ([]*spoolRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil, os.ErrClosed
+	}
+	return s.read()
+}
+
+// truncate drops the first n records. Records spooled during the replay
+// were appended after them and are kept.
+func (s *SpoolPublisher) truncate(n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return os.ErrClosed
+	}
+	records, err := s.read()
+	if err != nil {
+		return err
+	}
+	return s.rewrite(records[min(n, len(records)):])
+}
+
+func (s *SpoolPublisher) replayRecord(ctx context.Context, record *spoolRecord, send replaySend) (err error) {
+	p := s.publisher
+	msg := &record.Publishing
+
+	origin := p.config.Propagator.ExtractFromHeaders(context.Background(), msg.Headers)
+	opts := []trace.SpanStartOption{trace.WithAttributes(attribute.Bool(internal.MessagingOrbSpoolReplayed, true))}
+	if sc := trace.SpanContextFromContext(origin); sc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+
+	ctx, span := p.startSpan(ContextWithSpanStartOptions(ctx, opts...), record.Exchange, record.RoutingKey, msg)
+	defer func() {
+		internal.SafeSetSpanStatus(span, err)
+		span.End()
+	}()
+
+	p.config.Propagator.InjectToPublishing(ctx, msg)
+	confirm, err := send(ctx, record)
+	if err != nil {
+		return err
+	}
+	acked, err := confirm(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for replay confirmation: %w", err)
+	}
+	if !acked {
+		return ErrReplayNacked
+	}
+	return nil
+}
+
+func (s *SpoolPublisher) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *SpoolPublisher) open() error {
+	var err error
+	s.file, err = os.OpenFile(s.config.Path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	records, err := s.read()
+	if err != nil {
+		s.file.Close()
+		return err
+	}
+	// Rewriting drops a torn final record left by a crash.
+	return s.rewrite(records)
+}
+
+func (s *SpoolPublisher) read() ([]*spoolRecord, error) {
+	if _, err := s.file.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("failed to read spool: %w", err)
+	}
+
+	var records []*spoolRecord
+	reader := bufio.NewReader(s.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A record without its newline was not completely written.
+			break
+		}
+		record, err := decodeSpoolRecord(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode spooled message: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func decodeSpoolRecord(line []byte) (*spoolRecord, error) {
+	var raw struct {
+		spoolRecord
+		Publishing json.RawMessage `json:"publishing"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil, err
+	}
+	record := raw.spoolRecord
+	if err := internal.UnmarshalPublishing(raw.Publishing, &record.Publishing); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// rewrite replaces the spool file with records.
+func (s *SpoolPublisher) rewrite(records []*spoolRecord) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.config.Path), filepath.Base(s.config.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var size int64
+	w := bufio.NewWriter(tmp)
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to rewrite spool: %w", err)
+		}
+		n, _ := w.Write(append(line, '\n'))
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.config.Path); err != nil {
+		return fmt.Errorf("failed to rewrite spool: %w", err)
+	}
+
+	s.file.Close()
+	s.file, err = os.OpenFile(s.config.Path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool: %w", err)
+	}
+	s.depth = int64(len(records))
+	s.size = size
+	return nil
+}
+
+func (s *SpoolPublisher) blocked() bool {
+	return s.publisher.conn != nil && s.publisher.conn.IsBlocked()
+}
+
+type spoolingKey struct{}
+
+// isSpooling reports whether a blocked publish will be spooled rather than
+// failed.
+func isSpooling(ctx context.Context) bool {
+	spooling, _ := ctx.Value(spoolingKey{}).(bool)
+	return spooling
+}
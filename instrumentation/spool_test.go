@@ -0,0 +1,284 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func newTestSpool(t *testing.T, path string, maxBytes int64) (*SpoolPublisher, func() []sdktrace.ReadOnlySpan) {
+	t.Helper()
+	tracer, recorder := newTestTracer()
+	publisher := NewPublisher(PublisherConfig{
+		Tracer: tracer,
+		Propagator: NewPropagatorWithConfig(PropagatorConfig{
+			TextMapPropagator: propagation.TraceContext{},
+		}),
+	})
+	spool, err := NewSpoolPublisher(publisher, SpoolConfig{Path: path, MaxBytes: maxBytes})
+	if err != nil {
+		t.Fatalf("NewSpoolPublisher() error = %v", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	return spool, recorder.Ended
+}
+
+func spanBool(span sdktrace.ReadOnlySpan, key string) bool {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value.AsBool()
+		}
+	}
+	return false
+}
+
+func acked(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func TestSpoolPublisherSpoolsAndReplaysInOrder(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "publish.spool")
+	spool, ended := newTestSpool(t, path, 0)
+
+	failing := func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing) error {
+		return amqp091.ErrClosed
+	}
+	var sent []sentMessage
+	working := recordingSend(&sent)
+
+	msg := amqp091.Publishing{Headers: amqp091.Table{"attempt": int32(1)}, Body: []byte("first")}
+	if err := spool.publish(ctx, "orders", "order.created", false, false, &msg, failing); err != nil {
+		t.Fatalf("publish() error = %v, want message spooled", err)
+	}
+	// Later messages queue behind the spool even when the broker is back.
+	if err := spool.publish(ctx, "orders", "order.paid", false, false, &amqp091.Publishing{Body: []byte("second")}, working); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	if len(sent) != 0 || spool.Depth() != 2 {
+		t.Fatalf("sent = %d, depth = %d, want 0 and 2", len(sent), spool.Depth())
+	}
+	original := ended()[0]
+	if !spanBool(original, internal.MessagingOrbSpooled) {
+		t.Error("spooled publish span should be marked as spooled")
+	}
+
+	// Spooled messages survive a restart.
+	spool.Close()
+	spool, ended = newTestSpool(t, path, 0)
+	if spool.Depth() != 2 {
+		t.Fatalf("depth after reopen = %d, want 2", spool.Depth())
+	}
+
+	var replayed []*spoolRecord
+	n, err := spool.replay(ctx, func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+		replayed = append(replayed, record)
+		return acked, nil
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("replay() = %d, %v, want 2", n, err)
+	}
+	if replayed[0].RoutingKey != "order.created" || replayed[1].RoutingKey != "order.paid" {
+		t.Errorf("replayed out of order: %s, %s", replayed[0].RoutingKey, replayed[1].RoutingKey)
+	}
+	if replayed[0].Publishing.Headers["attempt"] != int64(1) {
+		t.Errorf("headers = %v", replayed[0].Publishing.Headers)
+	}
+	if spool.Depth() != 0 {
+		t.Errorf("depth after replay = %d, want 0", spool.Depth())
+	}
+
+	replaySpan := ended()[0]
+	if !spanBool(replaySpan, internal.MessagingOrbSpoolReplayed) {
+		t.Error("replayed publish span should be marked as replayed")
+	}
+	links := replaySpan.Links()
+	if len(links) != 1 || links[0].SpanContext.SpanID() != original.SpanContext().SpanID() {
+		t.Errorf("replay links = %v, want link to original publish span", links)
+	}
+}
+
+func TestSpoolPublisherReplayStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	spool, _ := newTestSpool(t, filepath.Join(t.TempDir(), "publish.spool"), 0)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = spool.publish(ctx, "", key, false, false, &amqp091.Publishing{}, nil)
+	}
+
+	calls := 0
+	n, err := spool.replay(ctx, func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+		calls++
+		if record.RoutingKey == "b" {
+			return nil, amqp091.ErrClosed
+		}
+		return acked, nil
+	})
+	if !errors.Is(err, amqp091.ErrClosed) || n != 1 || calls != 2 {
+		t.Fatalf("replay() = %d, %v after %d calls", n, err, calls)
+	}
+	if spool.Depth() != 2 {
+		t.Errorf("depth = %d, want 2", spool.Depth())
+	}
+}
+
+func TestSpoolPublisherKeepsNackedRecords(t *testing.T) {
+	ctx := context.Background()
+	spool, ended := newTestSpool(t, filepath.Join(t.TempDir(), "publish.spool"), 0)
+
+	for _, key := range []string{"a", "b", "c"} {
+		_ = spool.publish(ctx, "", key, false, false, &amqp091.Publishing{}, nil)
+	}
+
+	calls := 0
+	n, err := spool.replay(ctx, func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+		calls++
+		if record.RoutingKey == "b" {
+			return func(ctx context.Context) (bool, error) { return false, nil }, nil
+		}
+		return acked, nil
+	})
+	if !errors.Is(err, ErrReplayNacked) || n != 1 || calls != 2 {
+		t.Fatalf("replay() = %d, %v after %d calls, want 1 and %v", n, err, calls, ErrReplayNacked)
+	}
+
+	records, _ := spool.snapshot()
+	if len(records) != 2 || records[0].RoutingKey != "b" || records[1].RoutingKey != "c" {
+		t.Errorf("spool after nack = %v, want b and c", records)
+	}
+	if spans := ended(); spans[len(spans)-1].Status().Code != codes.Error {
+		t.Error("nacked replay span should have an error status")
+	}
+}
+
+// countingMeter records the sums of its Int64Counters by name.
+type countingMeter struct {
+	noop.Meter
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+type countingCounter struct {
+	noop.Int64Counter
+	meter *countingMeter
+	name  string
+}
+
+func (m *countingMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return countingCounter{meter: m, name: name}, nil
+}
+
+func (c countingCounter) Add(ctx context.Context, incr int64, opts ...metric.AddOption) {
+	c.meter.mu.Lock()
+	defer c.meter.mu.Unlock()
+	c.meter.counts[c.name] += incr
+}
+
+func TestSpoolPublisherDropsExpiredRecords(t *testing.T) {
+	meter := &countingMeter{counts: make(map[string]int64)}
+	spool, err := NewSpoolPublisher(NewPublisher(PublisherConfig{PropagateDeadline: true}),
+		SpoolConfig{Path: filepath.Join(t.TempDir(), "publish.spool"), Meter: meter})
+	if err != nil {
+		t.Fatalf("NewSpoolPublisher() error = %v", err)
+	}
+	defer spool.Close()
+
+	expiring, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_ = spool.publish(expiring, "", "expired", false, false, &amqp091.Publishing{}, nil)
+	_ = spool.publish(context.Background(), "", "live", false, false, &amqp091.Publishing{}, nil)
+	<-expiring.Done()
+
+	var replayed []string
+	n, err := spool.replay(context.Background(), func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+		replayed = append(replayed, record.RoutingKey)
+		return acked, nil
+	})
+	if err != nil || n != 1 {
+		t.Fatalf("replay() = %d, %v, want 1", n, err)
+	}
+	if len(replayed) != 1 || replayed[0] != "live" {
+		t.Errorf("replayed = %v, want only the live message", replayed)
+	}
+	if spool.Depth() != 0 {
+		t.Errorf("depth = %d, want expired message removed", spool.Depth())
+	}
+	if meter.counts["orb.spool.expired"] != 1 || meter.counts["orb.spool.replayed"] != 1 {
+		t.Errorf("counters = %v, want one expired and one replayed", meter.counts)
+	}
+}
+
+func TestSpoolPublisherReplayDoesNotBlockSpooling(t *testing.T) {
+	ctx := context.Background()
+	spool, _ := newTestSpool(t, filepath.Join(t.TempDir(), "publish.spool"), 0)
+	_ = spool.publish(ctx, "", "a", false, false, &amqp091.Publishing{}, nil)
+
+	sending := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := spool.replay(ctx, func(ctx context.Context, record *spoolRecord) (confirmFunc, error) {
+			close(sending)
+			<-release
+			return acked, nil
+		})
+		done <- err
+	}()
+	<-sending
+
+	// The broker is stalled; spooling and Depth must not wait for it.
+	if err := spool.publish(ctx, "", "b", false, false, &amqp091.Publishing{}, nil); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	if spool.Depth() != 2 {
+		t.Errorf("depth during replay = %d, want 2", spool.Depth())
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("replay() error = %v", err)
+	}
+	records, _ := spool.snapshot()
+	if len(records) != 1 || records[0].RoutingKey != "b" || spool.Depth() != 1 {
+		t.Errorf("spool after replay = %v, depth %d, want only b", records, spool.Depth())
+	}
+}
+
+func TestSpoolPublisherFull(t *testing.T) {
+	spool, _ := newTestSpool(t, filepath.Join(t.TempDir(), "publish.spool"), 200)
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = spool.publish(context.Background(), "", "q", false, false, &amqp091.Publishing{Body: make([]byte, 50)}, nil)
+	}
+	if !errors.Is(err, ErrSpoolFull) {
+		t.Errorf("publish() error = %v, want %v", err, ErrSpoolFull)
+	}
+}
+
+func TestSpoolPublisherSpoolsWhileBlocked(t *testing.T) {
+	spool, _ := newTestSpool(t, filepath.Join(t.TempDir(), "publish.spool"), 0)
+	conn := NewConnection(nil, ConnectionConfig{})
+	conn.events.handleBlocked(amqp091.Blocking{Active: true, Reason: "disk alarm"})
+	spool.publisher.conn = conn
+	spool.publisher.config.FailFastWhenBlocked = true
+
+	var sent []sentMessage
+	if err := spool.publish(context.Background(), "", "q", false, false, &amqp091.Publishing{}, recordingSend(&sent)); err != nil {
+		t.Fatalf("publish() error = %v", err)
+	}
+	if len(sent) != 0 || spool.Depth() != 1 {
+		t.Errorf("sent = %d, depth = %d, want 0 and 1", len(sent), spool.Depth())
+	}
+}
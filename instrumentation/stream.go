@@ -0,0 +1,359 @@
+package instrumentation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultStreamPrefetch = 100
+	streamOffsetArgument  = "x-stream-offset"
+)
+
+// OffsetSpec is where a StreamConsumer starts reading a stream when no
+// offset has been stored.
+type OffsetSpec struct {
+	kind      string
+	offset    int64
+	timestamp time.Time
+}
+
+var (
+	OffsetFirst = OffsetSpec{kind: "first"}
+	OffsetLast  = OffsetSpec{kind: "last"}
+	OffsetNext  = OffsetSpec{kind: "next"}
+)
+
+// OffsetAt starts at a numeric offset.
+func OffsetAt(offset int64) OffsetSpec {
+	return OffsetSpec{kind: "offset", offset: offset}
+}
+
+// OffsetTimestamp starts at the first chunk containing messages published
+// at or after t.
+func OffsetTimestamp(t time.Time) OffsetSpec {
+	return OffsetSpec{kind: "timestamp", timestamp: t}
+}
+
+// ParseOffsetSpec parses "first", "last", "next", a numeric offset or an
+// RFC 3339 timestamp.
+func ParseOffsetSpec(s string) (OffsetSpec, error) {
+	trimmed := strings.TrimSpace(s)
+	for _, spec := range []OffsetSpec{OffsetFirst, OffsetLast, OffsetNext} {
+		if strings.EqualFold(trimmed, spec.kind) {
+			return spec, nil
+		}
+	}
+	if trimmed == "" {
+		return OffsetNext, nil
+	}
+	if offset, err := strconv.ParseInt(trimmed, 10, 64); err == nil && offset >= 0 {
+		return OffsetAt(offset), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, trimmed); err == nil {
+		return OffsetTimestamp(t), nil
+	}
+	return OffsetSpec{}, fmt.Errorf("invalid stream offset %q", s)
+}
+
+func (o OffsetSpec) String() string {
+	switch o.kind {
+	case "offset":
+		return strconv.FormatInt(o.offset, 10)
+	case "timestamp":
+		return o.timestamp.Format(time.RFC3339Nano)
+	case "":
+		return OffsetNext.kind
+	default:
+		return o.kind
+	}
+}
+
+// argument returns the x-stream-offset consumer argument.
+func (o OffsetSpec) argument() any {
+	switch o.kind {
+	case "offset":
+		return o.offset
+	case "timestamp":
+		return o.timestamp
+	case "":
+		return OffsetNext.kind
+	default:
+		return o.kind
+	}
+}
+
+// OffsetStore persists the last processed offset of each stream consumer.
+type OffsetStore interface {
+	Load(ctx context.Context, stream, consumer string) (offset int64, ok bool, err error)
+	Store(ctx context.Context, stream, consumer string, offset int64) error
+}
+
+type StreamConsumerConfig struct {
+	// Name identifies the consumer in the OffsetStore. Defaults to the
+	// stream name.
+	Name        string
+	ConsumerTag string
+	// Offset is used when the OffsetStore has no offset. Defaults to
+	// OffsetNext.
+	Offset      OffsetSpec
+	OffsetStore OffsetStore
+	// Prefetch is required by stream queues. Defaults to
+	// DefaultStreamPrefetch.
+	Prefetch int
+	// CommitInterval stores the offset every CommitInterval messages.
+	// Defaults to 1.
+	CommitInterval int
+}
+
+// StreamConsumer consumes a RabbitMQ stream queue over AMQP 0-9-1 with manual
+// acknowledgements, records messaging.rabbitmq.stream.offset on every span
+// and resumes after the last stored offset.
+//
+// Streams cannot redeliver a message, so failed deliveries are nacked
+// without requeueing and a handler error stops consumption before its offset
+// is stored, letting a restart retry it. Dead-lettered errors are treated as
+// processed.
+type StreamConsumer struct {
+	consumer *Consumer
+	config   StreamConsumerConfig
+}
+
+func NewStreamConsumer(consumer *Consumer, config StreamConsumerConfig) *StreamConsumer {
+	if consumer == nil {
+		consumer = NewDefaultConsumer()
+	}
+	if config.Prefetch <= 0 {
+		config.Prefetch = DefaultStreamPrefetch
+	}
+	if config.CommitInterval <= 0 {
+		config.CommitInterval = 1
+	}
+	return &StreamConsumer{
+		consumer: consumer,
+		config:   config,
+	}
+}
+
+// Consume reads stream until ctx is cancelled, the channel closes or the
+// handler fails. It blocks while consuming.
+func (s *StreamConsumer) Consume(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	stream string,
+	handler MessageHandler,
+) error {
+	start, err := s.startOffset(ctx, stream)
+	if err != nil {
+		return err
+	}
+	if err := channel.Qos(s.config.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set stream prefetch: %w", err)
+	}
+
+	consumeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	deliveries, err := channel.ConsumeWithContext(
+		consumeCtx, stream, s.config.ConsumerTag, false, false, false, false,
+		amqp091.Table{streamOffsetArgument: start.argument()},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming stream: %w", err)
+	}
+	return s.consume(ctx, stream, deliveries, handler)
+}
+
+// startOffset resumes after the stored offset or falls back to the
+// configured spec.
+func (s *StreamConsumer) startOffset(ctx context.Context, stream string) (OffsetSpec, error) {
+	if s.config.OffsetStore == nil {
+		return s.config.Offset, nil
+	}
+	offset, ok, err := s.config.OffsetStore.Load(ctx, stream, s.name(stream))
+	if err != nil {
+		return OffsetSpec{}, fmt.Errorf("failed to load stream offset: %w", err)
+	}
+	if !ok {
+		return s.config.Offset, nil
+	}
+	return OffsetAt(offset + 1), nil
+}
+
+func (s *StreamConsumer) consume(
+	ctx context.Context,
+	stream string,
+	deliveries <-chan amqp091.Delivery,
+	handler MessageHandler,
+) (err error) {
+	var (
+		last      int64
+		pending   int
+		committed = true
+	)
+	commit := func() error {
+		if committed || s.config.OffsetStore == nil {
+			return nil
+		}
+		committed = true
+		pending = 0
+		if err := s.config.OffsetStore.Store(context.WithoutCancel(ctx), stream, s.name(stream), last); err != nil {
+			return fmt.Errorf("failed to store stream offset: %w", err)
+		}
+		return nil
+	}
+	defer func() {
+		err = errors.Join(err, commit())
+	}()
+
+	for {
+		var delivery amqp091.Delivery
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case delivery, ok = <-deliveries:
+		}
+		if !ok {
+			return nil
+		}
+
+		offset, hasOffset := streamOffset(delivery.Headers)
+		deliveryCtx := ctx
+		if hasOffset {
+			deliveryCtx = ContextWithSpanStartOptions(ctx, trace.WithAttributes(
+				attribute.Int64(internal.MessagingRabbitMQStreamOffset, offset),
+			))
+		}
+
+		if err := s.consumer.processDelivery(deliveryCtx, stream, delivery, handler, false, false); err != nil && !IsDeadLetter(err) {
+			if hasOffset {
+				return fmt.Errorf("failed to process stream offset %d: %w", offset, err)
+			}
+			return err
+		}
+
+		if hasOffset {
+			last, committed = offset, false
+			if pending++; pending >= s.config.CommitInterval {
+				if err := commit(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *StreamConsumer) name(stream string) string {
+	if s.config.Name != "" {
+		return s.config.Name
+	}
+	return stream
+}
+
+func streamOffset(headers amqp091.Table) (int64, bool) {
+	switch v := headers[streamOffsetArgument].(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// MemoryOffsetStore keeps offsets in memory, for tests and consumers that
+// always start from a fixed spec after a restart.
+type MemoryOffsetStore struct {
+	mu      sync.Mutex
+	offsets map[string]int64
+}
+
+func NewMemoryOffsetStore() *MemoryOffsetStore {
+	return &MemoryOffsetStore{offsets: make(map[string]int64)}
+}
+
+func (s *MemoryOffsetStore) Load(ctx context.Context, stream, consumer string) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	offset, ok := s.offsets[stream+"/"+consumer]
+	return offset, ok, nil
+}
+
+func (s *MemoryOffsetStore) Store(ctx context.Context, stream, consumer string, offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offsets[stream+"/"+consumer] = offset
+	return nil
+}
+
+// FileOffsetStore keeps offsets in a JSON file that is replaced atomically
+// on every store.
+type FileOffsetStore struct {
+	path string
+	mem  *MemoryOffsetStore
+}
+
+func NewFileOffsetStore(path string) (*FileOffsetStore, error) {
+	s := &FileOffsetStore{path: path, mem: NewMemoryOffsetStore()}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offset store: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.mem.offsets); err != nil {
+		return nil, fmt.Errorf("failed to decode offset store: %w", err)
+	}
+	// A file containing null decodes to a nil map.
+	if s.mem.offsets == nil {
+		s.mem.offsets = make(map[string]int64)
+	}
+	return s, nil
+}
+
+func (s *FileOffsetStore) Load(ctx context.Context, stream, consumer string) (int64, bool, error) {
+	return s.mem.Load(ctx, stream, consumer)
+}
+
+func (s *FileOffsetStore) Store(ctx context.Context, stream, consumer string, offset int64) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	s.mem.offsets[stream+"/"+consumer] = offset
+
+	data, err := json.Marshal(s.mem.offsets)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,213 @@
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+)
+
+func TestParseOffsetSpec(t *testing.T) {
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want OffsetSpec
+	}{
+		{"first", OffsetFirst},
+		{"LAST", OffsetLast},
+		{"next", OffsetNext},
+		{"", OffsetNext},
+		{" Next ", OffsetNext},
+		{"42", OffsetAt(42)},
+		{"2024-06-01T12:00:00Z", OffsetTimestamp(ts)},
+	}
+	for _, tt := range tests {
+		got, err := ParseOffsetSpec(tt.in)
+		if err != nil {
+			t.Errorf("ParseOffsetSpec(%q): %v", tt.in, err)
+			continue
+		}
+		if got.String() != tt.want.String() {
+			t.Errorf("ParseOffsetSpec(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"-1", "Yesterday"} {
+		_, err := ParseOffsetSpec(in)
+		if err == nil {
+			t.Errorf("ParseOffsetSpec(%q) should fail", in)
+		} else if !strings.Contains(err.Error(), strconv.Quote(in)) {
+			t.Errorf("ParseOffsetSpec(%q) error = %v, want the original input", in, err)
+		}
+	}
+
+	if arg := OffsetAt(7).argument(); arg != int64(7) {
+		t.Errorf("offset argument = %#v, want int64(7)", arg)
+	}
+	if arg := OffsetTimestamp(ts).argument(); arg != ts {
+		t.Errorf("timestamp argument = %#v, want %v", arg, ts)
+	}
+}
+
+func TestStreamConsumerStartOffset(t *testing.T) {
+	store := NewMemoryOffsetStore()
+	s := NewStreamConsumer(nil, StreamConsumerConfig{Offset: OffsetFirst, OffsetStore: store})
+
+	start, err := s.startOffset(context.Background(), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != OffsetFirst {
+		t.Errorf("start = %s, want first", start)
+	}
+
+	_ = store.Store(context.Background(), "events", "events", 41)
+	start, err = s.startOffset(context.Background(), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != OffsetAt(42) {
+		t.Errorf("start = %s, want 42", start)
+	}
+}
+
+func streamDeliveries(offsets ...int64) (chan amqp091.Delivery, []*fakeAcknowledger) {
+	deliveries := make(chan amqp091.Delivery, len(offsets))
+	var acks []*fakeAcknowledger
+	for _, offset := range offsets {
+		ack := &fakeAcknowledger{}
+		acks = append(acks, ack)
+		deliveries <- amqp091.Delivery{
+			Acknowledger: ack,
+			Headers:      amqp091.Table{"x-stream-offset": offset},
+		}
+	}
+	close(deliveries)
+	return deliveries, acks
+}
+
+func TestStreamConsumerStoresOffsets(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	store := NewMemoryOffsetStore()
+	s := NewStreamConsumer(NewConsumer(ConsumerConfig{Tracer: tracer}), StreamConsumerConfig{
+		Name:           "billing",
+		OffsetStore:    store,
+		CommitInterval: 2,
+	})
+
+	handler := func(ctx context.Context, delivery amqp091.Delivery) error {
+		if delivery.Headers["x-stream-offset"] == int64(11) {
+			return DeadLetter(errors.New("poison"))
+		}
+		return nil
+	}
+	deliveries, _ := streamDeliveries(10, 11, 12)
+	if err := s.consume(context.Background(), "events", deliveries, handler); err != nil {
+		t.Fatal(err)
+	}
+
+	offset, ok, _ := store.Load(context.Background(), "events", "billing")
+	if !ok || offset != 12 {
+		t.Errorf("stored offset = %d, %v, want 12", offset, ok)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("ended spans = %d, want 3", len(spans))
+	}
+	for i, span := range spans {
+		found := false
+		for _, attr := range span.Attributes() {
+			if string(attr.Key) == internal.MessagingRabbitMQStreamOffset {
+				found = true
+				if attr.Value.AsInt64() != int64(10+i) {
+					t.Errorf("span %d offset = %d, want %d", i, attr.Value.AsInt64(), 10+i)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("span %d has no stream offset", i)
+		}
+	}
+}
+
+func TestStreamConsumerStopsOnError(t *testing.T) {
+	store := NewMemoryOffsetStore()
+	s := NewStreamConsumer(nil, StreamConsumerConfig{OffsetStore: store})
+
+	calls := 0
+	handler := func(ctx context.Context, delivery amqp091.Delivery) error {
+		calls++
+		if calls == 2 {
+			return errors.New("database down")
+		}
+		return nil
+	}
+	deliveries, acks := streamDeliveries(0, 1, 2)
+	err := s.consume(context.Background(), "events", deliveries, handler)
+	if err == nil {
+		t.Fatal("expected handler error")
+	}
+	if acks[0].acked != 1 {
+		t.Error("processed delivery should be acked")
+	}
+	if acks[1].nacked != 1 || acks[1].requeue {
+		t.Errorf("failed delivery nacked = %d, requeue = %v, want one nack without requeue", acks[1].nacked, acks[1].requeue)
+	}
+	if calls != 2 {
+		t.Errorf("handler calls = %d, want 2", calls)
+	}
+
+	offset, ok, _ := store.Load(context.Background(), "events", "events")
+	if !ok || offset != 0 {
+		t.Errorf("stored offset = %d, %v, want 0", offset, ok)
+	}
+}
+
+func TestFileOffsetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	store, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Load(context.Background(), "events", "billing"); ok {
+		t.Error("empty store should have no offset")
+	}
+	if err := store.Store(context.Background(), "events", "billing", 99); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, ok, _ := reopened.Load(context.Background(), "events", "billing")
+	if !ok || offset != 99 {
+		t.Errorf("offset = %d, %v, want 99", offset, ok)
+	}
+}
+
+func TestFileOffsetStoreNullFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.json")
+	if err := os.WriteFile(path, []byte("null"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileOffsetStore(path)
+	if err != nil {
+		t.Fatalf("NewFileOffsetStore() error = %v", err)
+	}
+	if err := store.Store(context.Background(), "events", "billing", 7); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if offset, ok, _ := store.Load(context.Background(), "events", "billing"); !ok || offset != 7 {
+		t.Errorf("offset = %d, %v, want 7", offset, ok)
+	}
+}
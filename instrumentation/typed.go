@@ -0,0 +1,168 @@
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/codec"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type TypedPublisherConfig struct {
+	// Codec encodes published values. Defaults to codec.JSON.
+	Codec codec.Codec
+	// MessageType is set as the AMQP Type property. Defaults to the Go type
+	// name of T.
+	MessageType string
+}
+
+// TypedPublisher encodes values of type T with a codec before publishing them
+// through a Publisher.
+type TypedPublisher[T any] struct {
+	publisher *Publisher
+	config    TypedPublisherConfig
+}
+
+func NewTypedPublisher[T any](publisher *Publisher, config TypedPublisherConfig) *TypedPublisher[T] {
+	if publisher == nil {
+		publisher = NewDefaultPublisher()
+	}
+	if config.Codec == nil {
+		config.Codec = codec.JSON
+	}
+	if config.MessageType == "" {
+		config.MessageType = typeName[T]()
+	}
+
+	return &TypedPublisher[T]{
+		publisher: publisher,
+		config:    config,
+	}
+}
+
+func (p *TypedPublisher[T]) Publish(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	value T,
+) error {
+	return p.PublishWithProperties(ctx, channel, exchange, routingKey, false, false, value, amqp091.Publishing{})
+}
+
+// PublishWithProperties publishes value using msg for every property other
+// than the body. ContentType and Type are filled in when empty.
+func (p *TypedPublisher[T]) PublishWithProperties(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	value T,
+	msg amqp091.Publishing,
+) error {
+	if err := p.encode(value, &msg); err != nil {
+		return err
+	}
+	return p.publisher.Publish(ctx, channel, exchange, routingKey, mandatory, immediate, msg)
+}
+
+func (p *TypedPublisher[T]) PublishWithConfirm(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	exchange, routingKey string,
+	mandatory, immediate bool,
+	value T,
+	msg amqp091.Publishing,
+) (*amqp091.DeferredConfirmation, error) {
+	if err := p.encode(value, &msg); err != nil {
+		return nil, err
+	}
+	return p.publisher.PublishWithConfirm(ctx, channel, exchange, routingKey, mandatory, immediate, msg)
+}
+
+func (p *TypedPublisher[T]) encode(value T, msg *amqp091.Publishing) error {
+	body, err := p.config.Codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", p.config.MessageType, err)
+	}
+
+	msg.Body = body
+	if msg.ContentType == "" {
+		msg.ContentType = p.config.Codec.ContentType()
+	}
+	if msg.Type == "" {
+		msg.Type = p.config.MessageType
+	}
+	return nil
+}
+
+type TypedHandler[T any] func(ctx context.Context, value T, delivery amqp091.Delivery) error
+
+type TypedConsumerConfig struct {
+	// Codec decodes delivered bodies. Defaults to codec.JSON.
+	Codec codec.Codec
+}
+
+// TypedConsumer decodes deliveries into values of type T before calling a
+// TypedHandler. Messages that cannot be decoded are dead-lettered.
+type TypedConsumer[T any] struct {
+	consumer *Consumer
+	config   TypedConsumerConfig
+}
+
+func NewTypedConsumer[T any](consumer *Consumer, config TypedConsumerConfig) *TypedConsumer[T] {
+	if consumer == nil {
+		consumer = NewDefaultConsumer()
+	}
+	if config.Codec == nil {
+		config.Codec = codec.JSON
+	}
+
+	return &TypedConsumer[T]{
+		consumer: consumer,
+		config:   config,
+	}
+}
+
+func (c *TypedConsumer[T]) ConsumeWithHandler(
+	ctx context.Context,
+	channel *amqp091.Channel,
+	queueName, consumerTag string,
+	autoAck, exclusive, noLocal, noWait bool,
+	args amqp091.Table,
+	handler TypedHandler[T],
+) error {
+	return c.consumer.ConsumeWithHandler(
+		ctx, channel, queueName, consumerTag, autoAck, exclusive, noLocal, noWait, args, c.Handler(handler),
+	)
+}
+
+func (c *TypedConsumer[T]) ProcessDelivery(
+	ctx context.Context,
+	queueName string,
+	delivery amqp091.Delivery,
+	handler TypedHandler[T],
+) error {
+	return c.consumer.ProcessDelivery(ctx, queueName, delivery, c.Handler(handler))
+}
+
+// Handler adapts a TypedHandler to a MessageHandler.
+func (c *TypedConsumer[T]) Handler(handler TypedHandler[T]) MessageHandler {
+	return func(ctx context.Context, delivery amqp091.Delivery) error {
+		var value T
+		if err := c.config.Codec.Unmarshal(delivery.Body, &value); err != nil {
+			err = fmt.Errorf("failed to decode %s message: %w", c.config.Codec.ContentType(), err)
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool(internal.MessagingOrbDecodeFailed, true))
+			return DeadLetter(err)
+		}
+		return handler(ctx, value, delivery)
+	}
+}
+
+func typeName[T any]() string {
+	return strings.TrimPrefix(reflect.TypeOf((*T)(nil)).Elem().String(), "*")
+}
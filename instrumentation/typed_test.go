@@ -0,0 +1,108 @@
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/codec"
+	"github.com/startower-observability/orb/internal"
+)
+
+type orderCreated struct {
+	ID     string `json:"id"`
+	Amount int    `json:"amount"`
+}
+
+func TestTypedPublisherEncode(t *testing.T) {
+	publisher := NewTypedPublisher[orderCreated](nil, TypedPublisherConfig{})
+
+	msg := amqp091.Publishing{MessageId: "msg-1"}
+	if err := publisher.encode(orderCreated{ID: "o-1", Amount: 10}, &msg); err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+
+	if string(msg.Body) != `{"id":"o-1","amount":10}` {
+		t.Errorf("Body = %s", msg.Body)
+	}
+	if msg.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", msg.ContentType)
+	}
+	if msg.Type != "instrumentation.orderCreated" {
+		t.Errorf("Type = %q, want instrumentation.orderCreated", msg.Type)
+	}
+	if msg.MessageId != "msg-1" {
+		t.Error("encode() should keep existing properties")
+	}
+}
+
+func TestTypedPublisherMessageTypeOverride(t *testing.T) {
+	publisher := NewTypedPublisher[*orderCreated](nil, TypedPublisherConfig{Codec: codec.Msgpack})
+	if publisher.config.MessageType != "instrumentation.orderCreated" {
+		t.Errorf("MessageType = %q, want pointer prefix stripped", publisher.config.MessageType)
+	}
+
+	override := NewTypedPublisher[orderCreated](nil, TypedPublisherConfig{MessageType: "orders.v1.created"})
+	msg := amqp091.Publishing{}
+	_ = override.encode(orderCreated{}, &msg)
+	if msg.Type != "orders.v1.created" {
+		t.Errorf("Type = %q, want configured message type", msg.Type)
+	}
+}
+
+func TestTypedConsumerDecodes(t *testing.T) {
+	consumer := NewTypedConsumer[orderCreated](nil, TypedConsumerConfig{})
+
+	var got orderCreated
+	ack := &fakeAcknowledger{}
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: ack, Body: []byte(`{"id":"o-2","amount":5}`)},
+		func(ctx context.Context, value orderCreated, delivery amqp091.Delivery) error {
+			got = value
+			return nil
+		})
+
+	if got != (orderCreated{ID: "o-2", Amount: 5}) {
+		t.Errorf("decoded value = %+v", got)
+	}
+	if ack.acked != 1 {
+		t.Errorf("acked = %d, want 1", ack.acked)
+	}
+}
+
+func TestTypedConsumerDeadLettersDecodeErrors(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	consumer := NewTypedConsumer[orderCreated](NewConsumer(ConsumerConfig{Tracer: tracer}), TypedConsumerConfig{})
+
+	called := false
+	ack := &fakeAcknowledger{}
+	_ = consumer.ProcessDelivery(context.Background(), "orders",
+		amqp091.Delivery{Acknowledger: ack, Body: []byte(`not json`), Type: "instrumentation.orderCreated"},
+		func(ctx context.Context, value orderCreated, delivery amqp091.Delivery) error {
+			called = true
+			return nil
+		})
+
+	if called {
+		t.Error("handler should not be called for undecodable messages")
+	}
+	if ack.nacked != 1 || ack.requeue {
+		t.Errorf("ack = %+v, want dead-lettered", ack)
+	}
+
+	span := recorder.Ended()[0]
+	if events := span.Events(); len(events) != 1 {
+		t.Errorf("span has %d events, want the decode error recorded once", len(events))
+	}
+
+	attrs := make(map[string]interface{})
+	for _, attr := range span.Attributes() {
+		attrs[string(attr.Key)] = attr.Value.AsInterface()
+	}
+	if attrs[internal.MessagingOrbDecodeFailed] != true {
+		t.Error("span should record the decode failure")
+	}
+	if attrs[internal.MessagingMessageType] != "instrumentation.orderCreated" {
+		t.Errorf("%s = %v", internal.MessagingMessageType, attrs[internal.MessagingMessageType])
+	}
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const MessagingHeaderPrefix = "messaging.header."
+
+func HeaderAttributeKey(name string) attribute.Key {
+	return attribute.Key(MessagingHeaderPrefix + strings.ToLower(name))
+}
+
+// HeaderValue converts a value decoded from an amqp091.Table into an
+// attribute value. Nested tables are rendered as JSON.
+func HeaderValue(value interface{}) (attribute.Value, bool) {
+	switch v := value.(type) {
+	case nil:
+		return attribute.Value{}, false
+	case string:
+		return attribute.StringValue(v), true
+	case []byte:
+		if utf8.Valid(v) {
+			return attribute.StringValue(string(v)), true
+		}
+		return attribute.StringValue(base64.StdEncoding.EncodeToString(v)), true
+	case bool:
+		return attribute.BoolValue(v), true
+	case int:
+		return attribute.Int64Value(int64(v)), true
+	case int8:
+		return attribute.Int64Value(int64(v)), true
+	case int16:
+		return attribute.Int64Value(int64(v)), true
+	case int32:
+		return attribute.Int64Value(int64(v)), true
+	case int64:
+		return attribute.Int64Value(v), true
+	case uint8:
+		return attribute.Int64Value(int64(v)), true
+	case uint16:
+		return attribute.Int64Value(int64(v)), true
+	case uint32:
+		return attribute.Int64Value(int64(v)), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return attribute.StringValue(fmt.Sprint(v)), true
+		}
+		return attribute.Int64Value(int64(v)), true
+	case float32:
+		return attribute.Float64Value(float64(v)), true
+	case float64:
+		return attribute.Float64Value(v), true
+	case time.Time:
+		return attribute.StringValue(v.UTC().Format(time.RFC3339Nano)), true
+	case amqp091.Decimal:
+		return attribute.StringValue(formatDecimal(v)), true
+	case []interface{}:
+		return sliceValue(v), true
+	case amqp091.Table:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return attribute.StringValue(fmt.Sprint(v)), true
+		}
+		return attribute.StringValue(string(encoded)), true
+	default:
+		return attribute.StringValue(fmt.Sprint(v)), true
+	}
+}
+
+func sliceValue(values []interface{}) attribute.Value {
+	converted := make([]attribute.Value, 0, len(values))
+	kind := attribute.INVALID
+	for _, value := range values {
+		v, ok := HeaderValue(value)
+		if !ok {
+			continue
+		}
+		if kind == attribute.INVALID {
+			kind = v.Type()
+		} else if kind != v.Type() {
+			kind = attribute.STRING
+		}
+		converted = append(converted, v)
+	}
+
+	switch kind {
+	case attribute.BOOL:
+		out := make([]bool, len(converted))
+		for i, v := range converted {
+			out[i] = v.AsBool()
+		}
+		return attribute.BoolSliceValue(out)
+	case attribute.INT64:
+		out := make([]int64, len(converted))
+		for i, v := range converted {
+			out[i] = v.AsInt64()
+		}
+		return attribute.Int64SliceValue(out)
+	case attribute.FLOAT64:
+		out := make([]float64, len(converted))
+		for i, v := range converted {
+			out[i] = v.AsFloat64()
+		}
+		return attribute.Float64SliceValue(out)
+	default:
+		out := make([]string, len(converted))
+		for i, v := range converted {
+			out[i] = v.Emit()
+		}
+		return attribute.StringSliceValue(out)
+	}
+}
+
+func formatDecimal(d amqp091.Decimal) string {
+	return new(big.Rat).SetFrac(
+		big.NewInt(int64(d.Value)),
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Scale)), nil),
+	).FloatString(int(d.Scale))
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestHeaderAttributeKey(t *testing.T) {
+	if got := HeaderAttributeKey("X-Tenant"); got != "messaging.header.x-tenant" {
+		t.Errorf("HeaderAttributeKey() = %v, want messaging.header.x-tenant", got)
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  attribute.Value
+	}{
+		{"string", "tenant-a", attribute.StringValue("tenant-a")},
+		{"bytes", []byte("v2"), attribute.StringValue("v2")},
+		{"binary bytes", []byte{0xff}, attribute.StringValue("/w==")},
+		{"bool", true, attribute.BoolValue(true)},
+		{"int8", int8(-3), attribute.Int64Value(-3)},
+		{"int16", int16(7), attribute.Int64Value(7)},
+		{"int32", int32(42), attribute.Int64Value(42)},
+		{"int64", int64(1) << 40, attribute.Int64Value(1 << 40)},
+		{"uint16", uint16(9), attribute.Int64Value(9)},
+		{"float32", float32(1.5), attribute.Float64Value(1.5)},
+		{"float64", 2.25, attribute.Float64Value(2.25)},
+		{"time", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), attribute.StringValue("2024-01-02T03:04:05Z")},
+		{"decimal", amqp091.Decimal{Scale: 2, Value: 1234}, attribute.StringValue("12.34")},
+		{"string slice", []interface{}{"a", "b"}, attribute.StringSliceValue([]string{"a", "b"})},
+		{"int slice", []interface{}{int32(1), int64(2)}, attribute.Int64SliceValue([]int64{1, 2})},
+		{"mixed slice", []interface{}{"a", int32(1)}, attribute.StringSliceValue([]string{"a", "1"})},
+		{"table", amqp091.Table{"k": "v"}, attribute.StringValue(`{"k":"v"}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := HeaderValue(tt.value)
+			if !ok {
+				t.Fatal("HeaderValue() should convert value")
+			}
+			if got != tt.want {
+				t.Errorf("HeaderValue() = %v, want %v", got.Emit(), tt.want.Emit())
+			}
+		})
+	}
+
+	if _, ok := HeaderValue(nil); ok {
+		t.Error("HeaderValue(nil) should not produce a value")
+	}
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SemconvMode selects which messaging semantic conventions spans follow.
+type SemconvMode int
+
+const (
+	// SemconvDefault resolves the mode from OTEL_SEMCONV_STABILITY_OPT_IN.
+	SemconvDefault SemconvMode = iota
+	// SemconvOld emits the attributes this library has always emitted.
+	SemconvOld
+	// SemconvStable emits the stable messaging conventions only.
+	SemconvStable
+	// SemconvDup emits both, easing dashboard migrations.
+	SemconvDup
+)
+
+const (
+	SemconvStabilityOptInEnv = "OTEL_SEMCONV_STABILITY_OPT_IN"
+
+	MessagingDestinationName               = "messaging.destination.name"
+	MessagingOperationType                 = "messaging.operation.type"
+	MessagingOperationName                 = "messaging.operation.name"
+	MessagingMessageIDStable               = "messaging.message.id"
+	MessagingMessageConversationID         = "messaging.message.conversation_id"
+	MessagingRabbitMQDestinationRoutingKey = "messaging.rabbitmq.destination.routing_key"
+	OperationTypeSend                      = "send"
+)
+
+func (m SemconvMode) String() string {
+	switch m {
+	case SemconvOld:
+		return "old"
+	case SemconvStable:
+		return "stable"
+	case SemconvDup:
+		return "dup"
+	default:
+		return "default"
+	}
+}
+
+// ParseSemconvMode accepts the mode names returned by String.
+func ParseSemconvMode(s string) (SemconvMode, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "default":
+		return SemconvDefault, true
+	case "old":
+		return SemconvOld, true
+	case "stable":
+		return SemconvStable, true
+	case "dup":
+		return SemconvDup, true
+	}
+	return SemconvDefault, false
+}
+
+// SemconvModeFromOptIn interprets an OTEL_SEMCONV_STABILITY_OPT_IN value,
+// a comma-separated list in which "messaging" and "messaging/dup" are
+// relevant here.
+func SemconvModeFromOptIn(value string) SemconvMode {
+	mode := SemconvOld
+	for _, item := range strings.Split(value, ",") {
+		switch strings.TrimSpace(item) {
+		case "messaging/dup":
+			return SemconvDup
+		case "messaging":
+			mode = SemconvStable
+		}
+	}
+	return mode
+}
+
+// Resolve replaces SemconvDefault with the mode selected by the environment.
+func (m SemconvMode) Resolve() SemconvMode {
+	if m != SemconvDefault {
+		return m
+	}
+	return SemconvModeFromOptIn(os.Getenv(SemconvStabilityOptInEnv))
+}
+
+// ConvertAttributes rewrites attributes built with the old conventions for
+// mode, which must already be resolved.
+func ConvertAttributes(mode SemconvMode, attrs []attribute.KeyValue) []attribute.KeyValue {
+	if mode != SemconvStable && mode != SemconvDup {
+		return attrs
+	}
+
+	converted := make([]attribute.KeyValue, 0, len(attrs)+2)
+	var kind, destination string
+	hasExchange := false
+	for _, attr := range attrs {
+		switch attr.Key {
+		case MessagingDestinationKind:
+			kind = attr.Value.AsString()
+		case MessagingDestination:
+			destination = attr.Value.AsString()
+		case MessagingRabbitMQExchange:
+			hasExchange = true
+		}
+	}
+
+	for _, attr := range attrs {
+		stable, ok := stableAttribute(attr)
+		if mode == SemconvDup || !ok {
+			converted = append(converted, attr)
+		}
+		if ok {
+			converted = append(converted, stable...)
+		}
+	}
+
+	// Stable conventions have no destination kind, so publish spans name the
+	// exchange explicitly.
+	if kind == DestinationKindTopic && destination != "" && !hasExchange {
+		converted = append(converted, attribute.String(MessagingRabbitMQExchange, destination))
+	}
+	return converted
+}
+
+func stableAttribute(attr attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	switch attr.Key {
+	case MessagingDestination:
+		return []attribute.KeyValue{attribute.String(MessagingDestinationName, attr.Value.AsString())}, true
+	case MessagingDestinationKind:
+		return nil, true
+	case MessagingRabbitMQRoutingKey:
+		return []attribute.KeyValue{attribute.String(MessagingRabbitMQDestinationRoutingKey, attr.Value.AsString())}, true
+	case MessagingMessageID:
+		return []attribute.KeyValue{attribute.String(MessagingMessageIDStable, attr.Value.AsString())}, true
+	case MessagingConversationID:
+		return []attribute.KeyValue{attribute.String(MessagingMessageConversationID, attr.Value.AsString())}, true
+	case MessagingOperation:
+		operation := attr.Value.AsString()
+		operationType := operation
+		if operation == OperationPublish {
+			operationType = OperationTypeSend
+		}
+		return []attribute.KeyValue{
+			attribute.String(MessagingOperationType, operationType),
+			attribute.String(MessagingOperationName, operation),
+		}, true
+	}
+	return nil, false
+}
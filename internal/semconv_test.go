@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestSemconvModeFromOptIn(t *testing.T) {
+	tests := map[string]SemconvMode{
+		"":                        SemconvOld,
+		"http":                    SemconvOld,
+		"messaging":               SemconvStable,
+		"http, messaging":         SemconvStable,
+		"messaging/dup":           SemconvDup,
+		"messaging,messaging/dup": SemconvDup,
+	}
+	for value, want := range tests {
+		if got := SemconvModeFromOptIn(value); got != want {
+			t.Errorf("SemconvModeFromOptIn(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestConvertAttributes(t *testing.T) {
+	attrs := GetPublishAttributes("orders", "order.created", &amqp091.Publishing{MessageId: "msg-1"})
+
+	stable := attributeMap(ConvertAttributes(SemconvStable, attrs))
+	want := map[string]interface{}{
+		MessagingDestinationName:               "orders",
+		MessagingRabbitMQDestinationRoutingKey: "order.created",
+		MessagingMessageIDStable:               "msg-1",
+		MessagingOperationType:                 "send",
+		MessagingOperationName:                 "publish",
+		MessagingRabbitMQExchange:              "orders",
+		MessagingSystem:                        "rabbitmq",
+	}
+	for key, value := range want {
+		if stable[key] != value {
+			t.Errorf("stable %s = %v, want %v", key, stable[key], value)
+		}
+	}
+	for _, old := range []string{MessagingDestination, MessagingDestinationKind, MessagingOperation, MessagingMessageID} {
+		if _, ok := stable[old]; ok {
+			t.Errorf("stable mode should not emit %s", old)
+		}
+	}
+
+	dup := attributeMap(ConvertAttributes(SemconvDup, attrs))
+	if dup[MessagingDestination] != "orders" || dup[MessagingDestinationName] != "orders" {
+		t.Errorf("dup mode should emit both conventions, got %v", dup)
+	}
+
+	if got := ConvertAttributes(SemconvOld, attrs); len(got) != len(attrs) {
+		t.Errorf("old mode should leave attributes unchanged, got %v", got)
+	}
+}
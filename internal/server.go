@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	ServerAddress                = "server.address"
+	ServerPort                   = "server.port"
+	NetworkPeerAddress           = "network.peer.address"
+	NetworkPeerPort              = "network.peer.port"
+	NetworkLocalAddress          = "network.local.address"
+	NetworkProtocolName          = "network.protocol.name"
+	NetworkProtocolVersion       = "network.protocol.version"
+	MessagingRabbitMQVhost       = "messaging.rabbitmq.vhost"
+	MessagingRabbitMQUser        = "messaging.rabbitmq.user"
+	MessagingRabbitMQProduct     = "messaging.rabbitmq.server.product"
+	MessagingRabbitMQVersion     = "messaging.rabbitmq.server.version"
+	MessagingRabbitMQClusterName = "messaging.rabbitmq.cluster_name"
+	ProtocolAMQP                 = "amqp"
+	ProtocolAMQPVersion          = "0.9.1"
+)
+
+// URIAttributes describes the broker a connection was dialed to. The user
+// and password are not recorded; see UserAttributes.
+func URIAttributes(uri amqp091.URI) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String(NetworkProtocolName, ProtocolAMQP),
+		attribute.String(NetworkProtocolVersion, ProtocolAMQPVersion),
+	}
+	if uri.Host != "" {
+		attrs = append(attrs, attribute.String(ServerAddress, uri.Host))
+	}
+	if uri.Port != 0 {
+		attrs = append(attrs, attribute.Int(ServerPort, uri.Port))
+	}
+	if uri.Vhost != "" {
+		attrs = append(attrs, attribute.String(MessagingRabbitMQVhost, uri.Vhost))
+	}
+	return attrs
+}
+
+// UserAttributes records the user of the dial URL, never the password.
+func UserAttributes(uri amqp091.URI) []attribute.KeyValue {
+	if uri.Username == "" {
+		return nil
+	}
+	return []attribute.KeyValue{attribute.String(MessagingRabbitMQUser, uri.Username)}
+}
+
+// AddrAttributes describes the socket of a live connection. The ephemeral
+// local port is left out to keep the attributes low-cardinality.
+func AddrAttributes(remote, local net.Addr) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if host, port, ok := splitAddr(remote); ok {
+		attrs = append(attrs,
+			attribute.String(NetworkPeerAddress, host),
+			attribute.Int(NetworkPeerPort, port),
+		)
+	}
+	if host, _, ok := splitAddr(local); ok {
+		attrs = append(attrs, attribute.String(NetworkLocalAddress, host))
+	}
+	return attrs
+}
+
+// ServerAddrAttributes derives server.address and server.port from the
+// remote address when the dial URL is unknown.
+func ServerAddrAttributes(remote net.Addr) []attribute.KeyValue {
+	host, port, ok := splitAddr(remote)
+	if !ok {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attribute.String(ServerAddress, host),
+		attribute.Int(ServerPort, port),
+	}
+}
+
+// ServerPropertyAttributes reads the product, version and cluster name the
+// broker announces during the handshake.
+func ServerPropertyAttributes(properties amqp091.Table) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	for _, property := range []struct{ key, name string }{
+		{"product", MessagingRabbitMQProduct},
+		{"version", MessagingRabbitMQVersion},
+		{"cluster_name", MessagingRabbitMQClusterName},
+	} {
+		if value, ok := properties[property.key].(string); ok && value != "" {
+			attrs = append(attrs, attribute.String(property.name, value))
+		}
+	}
+	return attrs
+}
+
+func splitAddr(addr net.Addr) (string, int, bool) {
+	if addr == nil {
+		return "", 0, false
+	}
+	host, portStr, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return "", 0, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, false
+	}
+	return host, port, true
+}
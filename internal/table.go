@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// UnmarshalPublishing decodes a JSON encoded Publishing, restoring integer
+// header values as int64 instead of float64.
+func UnmarshalPublishing(data []byte, msg *amqp091.Publishing) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(msg); err != nil {
+		return err
+	}
+	msg.Headers = normalizeTable(msg.Headers)
+	return nil
+}
+
+func normalizeTable(table amqp091.Table) amqp091.Table {
+	for key, value := range table {
+		table[key] = normalizeValue(value)
+	}
+	return table
+}
+
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		return normalizeTable(amqp091.Table(v))
+	case []any:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+		return v
+	}
+	return value
+}
@@ -2,6 +2,9 @@ package internal
 
 import (
 	"context"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
@@ -19,6 +22,7 @@ const (
 	MessagingOperation          = "messaging.operation"
 	MessagingMessageID          = "messaging.message_id"
 	MessagingConversationID     = "messaging.conversation_id"
+	MessagingMessageType        = "messaging.message.type"
 	SystemRabbitMQ              = "rabbitmq"
 	DestinationKindQueue        = "queue"
 	DestinationKindTopic        = "topic"
@@ -27,6 +31,67 @@ const (
 	OperationProcess            = "process"
 )
 
+const (
+	MessageBodyEvent              = "messaging.message.body"
+	MessagingMessageBody          = "messaging.message.body"
+	MessagingMessageBodySize      = "messaging.message.body.size"
+	MessagingMessageBodyEncoding  = "messaging.message.body.encoding"
+	MessagingMessageBodyTruncated = "messaging.message.body.truncated"
+	BodyEncodingText              = "text"
+	BodyEncodingBase64            = "base64"
+)
+
+const (
+	MessagingRabbitMQExchange     = "messaging.rabbitmq.exchange"
+	MessagingRabbitMQRedelivered  = "messaging.rabbitmq.redelivered"
+	MessagingRabbitMQDeadLettered = "messaging.rabbitmq.dead_lettered"
+	MessagingOrbForceSample       = "messaging.orb.force_sample"
+	MessagingOrbLatencyExceeded   = "messaging.orb.latency_threshold_exceeded"
+	MessagingOrbPublishVetoed     = "messaging.orb.publish_vetoed"
+	MessagingOrbDeadlineExceeded  = "messaging.orb.deadline_exceeded"
+	MessagingOrbExpiredAction     = "messaging.orb.expired_action"
+	MessagingOrbDecodeFailed      = "messaging.orb.decode_failed"
+	MessagingOrbRoute             = "messaging.orb.route"
+	MessagingOrbBaggageDropped    = "messaging.orb.baggage_dropped"
+	MessagingOrbConnectionBlocked = "messaging.orb.connection_blocked"
+	MessagingOrbConnectAttempt    = "messaging.orb.connect.attempt"
+	MessagingOrbDuplicate         = "messaging.orb.duplicate"
+	MessagingOrbSpooled           = "messaging.orb.spooled"
+	MessagingOrbSpoolReplayed     = "messaging.orb.spool.replayed"
+	MessagingOrbRateLimitWait     = "messaging.orb.rate_limit.wait_ms"
+	MessagingOrbUnconfirmedWait   = "messaging.orb.rate_limit.unconfirmed_wait_ms"
+	MessagingOrbRateLimited       = "messaging.orb.rate_limited"
+	BaggageAttributePrefix        = "baggage."
+	ForceSampleHeader             = "x-orb-force-sample"
+	DeathHeader                   = "x-death"
+	DeadlineHeader                = "x-orb-deadline"
+)
+
+const (
+	MessagingOrbCompressionEncoding       = "messaging.orb.compression.encoding"
+	MessagingOrbCompressionOriginalSize   = "messaging.orb.compression.original_size"
+	MessagingOrbCompressionCompressedSize = "messaging.orb.compression.compressed_size"
+)
+
+const (
+	MessagingOrbSignatureResult = "messaging.orb.signature.result"
+	MessagingOrbSignatureKeyID  = "messaging.orb.signature.key_id"
+	SignatureHeader             = "x-orb-signature"
+	SignatureKeyIDHeader        = "x-orb-signature-key-id"
+	SignatureAlgorithmHeader    = "x-orb-signature-alg"
+	SignedPropertiesHeader      = "x-orb-signed-properties"
+)
+
+const (
+	MessagingOrbEncryptionKeyID = "messaging.orb.encryption.key_id"
+	EncryptionAlgorithmHeader   = "x-orb-encryption-alg"
+	EncryptionKeyIDHeader       = "x-orb-encryption-key-id"
+	EncryptionDataKeyHeader     = "x-orb-encryption-data-key"
+	EncryptionNonceHeader       = "x-orb-encryption-nonce"
+)
+
+const MessagingRabbitMQStreamOffset = "messaging.rabbitmq.stream.offset"
+
 type HeaderCarrier amqp091.Table
 
 func (hc HeaderCarrier) Get(key string) string {
@@ -84,6 +149,14 @@ func GetPublishAttributes(exchange, routingKey string, msg *amqp091.Publishing)
 		attrs = append(attrs, attribute.String(MessagingConversationID, msg.CorrelationId))
 	}
 
+	if msg.Type != "" {
+		attrs = append(attrs, attribute.String(MessagingMessageType, msg.Type))
+	}
+
+	if IsForceSampled(msg.Headers) {
+		attrs = append(attrs, attribute.Bool(MessagingOrbForceSample, true))
+	}
+
 	return attrs
 }
 
@@ -110,9 +183,73 @@ func GetConsumeAttributes(queueName string, delivery *amqp091.Delivery) []attrib
 		attrs = append(attrs, attribute.String(MessagingConversationID, delivery.CorrelationId))
 	}
 
+	if delivery.Type != "" {
+		attrs = append(attrs, attribute.String(MessagingMessageType, delivery.Type))
+	}
+
+	if delivery.Exchange != "" {
+		attrs = append(attrs, attribute.String(MessagingRabbitMQExchange, delivery.Exchange))
+	}
+
+	if delivery.Redelivered {
+		attrs = append(attrs, attribute.Bool(MessagingRabbitMQRedelivered, true))
+	}
+
+	if _, ok := delivery.Headers[DeathHeader]; ok {
+		attrs = append(attrs, attribute.Bool(MessagingRabbitMQDeadLettered, true))
+	}
+
+	if IsForceSampled(delivery.Headers) {
+		attrs = append(attrs, attribute.Bool(MessagingOrbForceSample, true))
+	}
+
 	return attrs
 }
 
+// MessageDeadline returns the publisher deadline carried in the
+// x-orb-deadline header as Unix milliseconds or an RFC 3339 timestamp.
+func MessageDeadline(headers amqp091.Table) (time.Time, bool) {
+	switch v := headers[DeadlineHeader].(type) {
+	case int64:
+		return time.UnixMilli(v), true
+	case int32:
+		return time.UnixMilli(int64(v)), true
+	case time.Time:
+		return v, true
+	case string:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.UnixMilli(ms), true
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// IsForceSampled reports whether the headers carry a truthy
+// x-orb-force-sample value.
+func IsForceSampled(headers amqp091.Table) bool {
+	switch v := headers[ForceSampleHeader].(type) {
+	case bool:
+		return v
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "1", "true", "yes", "on":
+			return true
+		}
+	case int8:
+		return v != 0
+	case int16:
+		return v != 0
+	case int32:
+		return v != 0
+	case int64:
+		return v != 0
+	}
+	return false
+}
+
 func InjectContext(ctx context.Context, headers amqp091.Table) {
 	if headers == nil {
 		return
@@ -3,6 +3,7 @@ package internal
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel/attribute"
@@ -165,3 +166,87 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.msg
 }
+
+func TestGetConsumeAttributesDeliveryState(t *testing.T) {
+	delivery := &amqp091.Delivery{
+		Exchange:    "orders",
+		Redelivered: true,
+		Headers: amqp091.Table{
+			DeathHeader:       []interface{}{amqp091.Table{"queue": "orders"}},
+			ForceSampleHeader: "true",
+		},
+	}
+
+	found := make(map[string]attribute.Value)
+	for _, attr := range GetConsumeAttributes("orders.process", delivery) {
+		found[string(attr.Key)] = attr.Value
+	}
+
+	if found[MessagingRabbitMQExchange].AsString() != "orders" {
+		t.Errorf("Missing or incorrect %s attribute", MessagingRabbitMQExchange)
+	}
+	for _, key := range []string{MessagingRabbitMQRedelivered, MessagingRabbitMQDeadLettered, MessagingOrbForceSample} {
+		if !found[key].AsBool() {
+			t.Errorf("Missing or incorrect %s attribute", key)
+		}
+	}
+}
+
+func TestIsForceSampled(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{true, true},
+		{"TRUE", true},
+		{"1", true},
+		{int32(1), true},
+		{"false", false},
+		{int64(0), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		headers := amqp091.Table{}
+		if tt.value != nil {
+			headers[ForceSampleHeader] = tt.value
+		}
+		if got := IsForceSampled(headers); got != tt.want {
+			t.Errorf("IsForceSampled(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMessageDeadline(t *testing.T) {
+	want := time.UnixMilli(1700000000123)
+
+	tests := []struct {
+		name  string
+		value interface{}
+		ok    bool
+	}{
+		{"unix millis", want.UnixMilli(), true},
+		{"string millis", "1700000000123", true},
+		{"rfc3339", want.UTC().Format(time.RFC3339Nano), true},
+		{"timestamp", want, true},
+		{"invalid", "tomorrow", false},
+		{"missing", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := amqp091.Table{}
+			if tt.value != nil {
+				headers[DeadlineHeader] = tt.value
+			}
+
+			got, ok := MessageDeadline(headers)
+			if ok != tt.ok {
+				t.Fatalf("MessageDeadline() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !got.Equal(want) {
+				t.Errorf("MessageDeadline() = %v, want %v", got, want)
+			}
+		})
+	}
+}
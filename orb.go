@@ -41,38 +41,199 @@ import (
 )
 
 type (
-	Channel          = instrumentation.Channel
-	Connection       = instrumentation.Connection
-	Publisher        = instrumentation.Publisher
-	Consumer         = instrumentation.Consumer
-	Propagator       = instrumentation.Propagator
-	MessageHandler   = instrumentation.MessageHandler
-	ChannelConfig    = instrumentation.ChannelConfig
-	ConnectionConfig = instrumentation.ConnectionConfig
-	PublisherConfig  = instrumentation.PublisherConfig
-	ConsumerConfig   = instrumentation.ConsumerConfig
+	Channel                = instrumentation.Channel
+	Connection             = instrumentation.Connection
+	Publisher              = instrumentation.Publisher
+	Consumer               = instrumentation.Consumer
+	Propagator             = instrumentation.Propagator
+	MessageHandler         = instrumentation.MessageHandler
+	ChannelConfig          = instrumentation.ChannelConfig
+	ConnectionConfig       = instrumentation.ConnectionConfig
+	PublisherConfig        = instrumentation.PublisherConfig
+	ConsumerConfig         = instrumentation.ConsumerConfig
+	ChannelPool            = instrumentation.ChannelPool
+	ChannelPoolConfig      = instrumentation.ChannelPoolConfig
+	ChannelPoolStats       = instrumentation.ChannelPoolStats
+	BodyCaptureConfig      = instrumentation.BodyCaptureConfig
+	HeaderCaptureConfig    = instrumentation.HeaderCaptureConfig
+	FilterMode             = instrumentation.FilterMode
+	PublishFilter          = instrumentation.PublishFilter
+	ConsumeFilter          = instrumentation.ConsumeFilter
+	DeliveryInfo           = instrumentation.DeliveryInfo
+	Middleware             = instrumentation.Middleware
+	PublishFunc            = instrumentation.PublishFunc
+	PublishInterceptor     = instrumentation.PublishInterceptor
+	ExpiredAction          = instrumentation.ExpiredAction
+	TypedPublisherConfig   = instrumentation.TypedPublisherConfig
+	TypedConsumerConfig    = instrumentation.TypedConsumerConfig
+	Router                 = instrumentation.Router
+	RouterConfig           = instrumentation.RouterConfig
+	RouteMatcher           = instrumentation.RouteMatcher
+	PropagatorConfig       = instrumentation.PropagatorConfig
+	BaggageConfig          = instrumentation.BaggageConfig
+	ConnectionBlockedError = instrumentation.ConnectionBlockedError
+	Option                 = instrumentation.Option
+	ReconnectPolicy        = instrumentation.ReconnectPolicy
+	SemconvMode            = instrumentation.SemconvMode
+	DedupStore             = instrumentation.DedupStore
+	IdempotencyConfig      = instrumentation.IdempotencyConfig
+	MemoryDedupStore       = instrumentation.MemoryDedupStore
+	FileDedupStore         = instrumentation.FileDedupStore
+	SpoolPublisher         = instrumentation.SpoolPublisher
+	SpoolConfig            = instrumentation.SpoolConfig
+	RateLimit              = instrumentation.RateLimit
+	RateLimitConfig        = instrumentation.RateLimitConfig
+	Compressor             = instrumentation.Compressor
+	CompressionConfig      = instrumentation.CompressionConfig
+	DecompressionConfig    = instrumentation.DecompressionConfig
+	Signer                 = instrumentation.Signer
+	Verifier               = instrumentation.Verifier
+	KeyResolver            = instrumentation.KeyResolver
+	SigningConfig          = instrumentation.SigningConfig
+	VerificationConfig     = instrumentation.VerificationConfig
+	VerificationAction     = instrumentation.VerificationAction
+	KeyProvider            = instrumentation.KeyProvider
+	FileKeyProvider        = instrumentation.FileKeyProvider
+	EncryptionConfig       = instrumentation.EncryptionConfig
+	DecryptionConfig       = instrumentation.DecryptionConfig
+	StreamConsumer         = instrumentation.StreamConsumer
+	StreamConsumerConfig   = instrumentation.StreamConsumerConfig
+	OffsetSpec             = instrumentation.OffsetSpec
+	OffsetStore            = instrumentation.OffsetStore
+	MemoryOffsetStore      = instrumentation.MemoryOffsetStore
+	FileOffsetStore        = instrumentation.FileOffsetStore
+)
+
+type (
+	TypedPublisher[T any] = instrumentation.TypedPublisher[T]
+	TypedConsumer[T any]  = instrumentation.TypedConsumer[T]
+	TypedHandler[T any]   = instrumentation.TypedHandler[T]
+)
+
+const (
+	FilterRecord              = instrumentation.FilterRecord
+	FilterSkip                = instrumentation.FilterSkip
+	FilterRecordOnErrorOrSlow = instrumentation.FilterRecordOnErrorOrSlow
+	ExpiredDeadLetter         = instrumentation.ExpiredDeadLetter
+	ExpiredDrop               = instrumentation.ExpiredDrop
+	DefaultDialTimeout        = instrumentation.DefaultDialTimeout
+	SemconvDefault            = instrumentation.SemconvDefault
+	SemconvOld                = instrumentation.SemconvOld
+	SemconvStable             = instrumentation.SemconvStable
+	SemconvDup                = instrumentation.SemconvDup
+	VerificationDeadLetter    = instrumentation.VerificationDeadLetter
+	VerificationDrop          = instrumentation.VerificationDrop
+	AlgorithmHMACSHA256       = instrumentation.AlgorithmHMACSHA256
+	AlgorithmEd25519          = instrumentation.AlgorithmEd25519
+	PropertyExchange          = instrumentation.PropertyExchange
+	PropertyRoutingKey        = instrumentation.PropertyRoutingKey
+	PropertyContentType       = instrumentation.PropertyContentType
+	PropertyContentEncoding   = instrumentation.PropertyContentEncoding
+	PropertyMessageID         = instrumentation.PropertyMessageID
+	PropertyCorrelationID     = instrumentation.PropertyCorrelationID
+	PropertyType              = instrumentation.PropertyType
+	PropertyTimestamp         = instrumentation.PropertyTimestamp
+	PropertyAppID             = instrumentation.PropertyAppID
+	PropertyUserID            = instrumentation.PropertyUserID
+	DefaultStreamPrefetch     = instrumentation.DefaultStreamPrefetch
 )
 
 var (
-	Dial                 = instrumentation.Dial
-	DialWithConfig       = instrumentation.DialWithConfig
-	DialConfig           = instrumentation.DialConfig
-	DialConfigWithConfig = instrumentation.DialConfigWithConfig
-	NewChannel           = instrumentation.NewChannel
-	NewDefaultChannel    = instrumentation.NewDefaultChannel
-	NewConnection        = instrumentation.NewConnection
-	NewDefaultConnection = instrumentation.NewDefaultConnection
-	NewPublisher         = instrumentation.NewPublisher
-	NewDefaultPublisher  = instrumentation.NewDefaultPublisher
-	NewConsumer          = instrumentation.NewConsumer
-	NewDefaultConsumer   = instrumentation.NewDefaultConsumer
-	NewPropagator        = instrumentation.NewPropagator
-	Publish              = instrumentation.Publish
-	PublishWithConfirm   = instrumentation.PublishWithConfirm
-	ConsumeWithHandler   = instrumentation.ConsumeWithHandler
-	ProcessDelivery      = instrumentation.ProcessDelivery
-	WrapDelivery         = instrumentation.WrapDelivery
-	InjectToPublishing   = instrumentation.InjectToPublishing
-	ExtractFromDelivery  = instrumentation.ExtractFromDelivery
-	DefaultPropagator    = instrumentation.DefaultPropagator
+	Open                        = instrumentation.Open
+	WithAMQPConfig              = instrumentation.WithAMQPConfig
+	WithTLS                     = instrumentation.WithTLS
+	WithConnectionName          = instrumentation.WithConnectionName
+	WithConnectionConfig        = instrumentation.WithConnectionConfig
+	WithChannelConfig           = instrumentation.WithChannelConfig
+	WithLogger                  = instrumentation.WithLogger
+	WithTracerProvider          = instrumentation.WithTracerProvider
+	WithMeterProvider           = instrumentation.WithMeterProvider
+	WithReconnect               = instrumentation.WithReconnect
+	WithDialTimeout             = instrumentation.WithDialTimeout
+	Dial                        = instrumentation.Dial
+	DialWithConfig              = instrumentation.DialWithConfig
+	DialConfig                  = instrumentation.DialConfig
+	DialConfigWithConfig        = instrumentation.DialConfigWithConfig
+	NewChannel                  = instrumentation.NewChannel
+	NewDefaultChannel           = instrumentation.NewDefaultChannel
+	NewConnection               = instrumentation.NewConnection
+	NewDefaultConnection        = instrumentation.NewDefaultConnection
+	NewPublisher                = instrumentation.NewPublisher
+	NewDefaultPublisher         = instrumentation.NewDefaultPublisher
+	NewConsumer                 = instrumentation.NewConsumer
+	NewDefaultConsumer          = instrumentation.NewDefaultConsumer
+	NewPropagator               = instrumentation.NewPropagator
+	NewPropagatorWithConfig     = instrumentation.NewPropagatorWithConfig
+	Publish                     = instrumentation.Publish
+	PublishWithConfirm          = instrumentation.PublishWithConfirm
+	ConsumeWithHandler          = instrumentation.ConsumeWithHandler
+	ProcessDelivery             = instrumentation.ProcessDelivery
+	WrapDelivery                = instrumentation.WrapDelivery
+	InjectToPublishing          = instrumentation.InjectToPublishing
+	ExtractFromDelivery         = instrumentation.ExtractFromDelivery
+	DefaultPropagator           = instrumentation.DefaultPropagator
+	NewChannelPool              = instrumentation.NewChannelPool
+	ErrChannelPoolClosed        = instrumentation.ErrChannelPoolClosed
+	DeadLetter                  = instrumentation.DeadLetter
+	IsDeadLetter                = instrumentation.IsDeadLetter
+	ContextWithDeliveryInfo     = instrumentation.ContextWithDeliveryInfo
+	DeliveryInfoFromContext     = instrumentation.DeliveryInfoFromContext
+	ContextWithSpanStartOptions = instrumentation.ContextWithSpanStartOptions
+	Chain                       = instrumentation.Chain
+	RecoveryMiddleware          = instrumentation.RecoveryMiddleware
+	TimeoutMiddleware           = instrumentation.TimeoutMiddleware
+	LoggingMiddleware           = instrumentation.LoggingMiddleware
+	MetricsMiddleware           = instrumentation.MetricsMiddleware
+	ValidationMiddleware        = instrumentation.ValidationMiddleware
+	IdempotencyMiddleware       = instrumentation.IdempotencyMiddleware
+	NewMemoryDedupStore         = instrumentation.NewMemoryDedupStore
+	NewFileDedupStore           = instrumentation.NewFileDedupStore
+	NewSpoolPublisher           = instrumentation.NewSpoolPublisher
+	ErrSpoolFull                = instrumentation.ErrSpoolFull
+	ErrReplayNacked             = instrumentation.ErrReplayNacked
+	ErrRateLimited              = instrumentation.ErrRateLimited
+	Gzip                        = instrumentation.Gzip
+	Deflate                     = instrumentation.Deflate
+	Zstd                        = instrumentation.Zstd
+	NewGzipCompressor           = instrumentation.NewGzipCompressor
+	NewZstdCompressor           = instrumentation.NewZstdCompressor
+	ErrDecompressedTooLarge     = instrumentation.ErrDecompressedTooLarge
+	NewHMACSigner               = instrumentation.NewHMACSigner
+	NewHMACVerifier             = instrumentation.NewHMACVerifier
+	NewEd25519Signer            = instrumentation.NewEd25519Signer
+	NewEd25519Verifier          = instrumentation.NewEd25519Verifier
+	ErrUnsigned                 = instrumentation.ErrUnsigned
+	ErrInvalidSignature         = instrumentation.ErrInvalidSignature
+	DefaultSignedProperties     = instrumentation.DefaultSignedProperties
+	NewFileKeyProvider          = instrumentation.NewFileKeyProvider
+	ErrNotEncrypted             = instrumentation.ErrNotEncrypted
+	ErrUnknownKey               = instrumentation.ErrUnknownKey
+	NewStreamConsumer           = instrumentation.NewStreamConsumer
+	OffsetFirst                 = instrumentation.OffsetFirst
+	OffsetLast                  = instrumentation.OffsetLast
+	OffsetNext                  = instrumentation.OffsetNext
+	OffsetAt                    = instrumentation.OffsetAt
+	OffsetTimestamp             = instrumentation.OffsetTimestamp
+	ParseOffsetSpec             = instrumentation.ParseOffsetSpec
+	NewMemoryOffsetStore        = instrumentation.NewMemoryOffsetStore
+	NewFileOffsetStore          = instrumentation.NewFileOffsetStore
+	ErrHandlerPanic             = instrumentation.ErrHandlerPanic
+	MessageIDInterceptor        = instrumentation.MessageIDInterceptor
+	HeadersInterceptor          = instrumentation.HeadersInterceptor
+	ErrPublishVetoed            = instrumentation.ErrPublishVetoed
+	ErrMessageExpired           = instrumentation.ErrMessageExpired
+	NewRouter                   = instrumentation.NewRouter
+	MatchRoutingKey             = instrumentation.MatchRoutingKey
+	MatchType                   = instrumentation.MatchType
+	MatchHeaders                = instrumentation.MatchHeaders
+	ErrNoRoute                  = instrumentation.ErrNoRoute
+	ErrConnectionBlocked        = instrumentation.ErrConnectionBlocked
 )
+
+func NewTypedPublisher[T any](publisher *Publisher, config TypedPublisherConfig) *TypedPublisher[T] {
+	return instrumentation.NewTypedPublisher[T](publisher, config)
+}
+
+func NewTypedConsumer[T any](consumer *Consumer, config TypedConsumerConfig) *TypedConsumer[T] {
+	return instrumentation.NewTypedConsumer[T](consumer, config)
+}
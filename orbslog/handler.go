@@ -0,0 +1,137 @@
+// Package orbslog connects log/slog records to orb traces.
+//
+// Wrapping a slog.Handler with NewHandler adds the active trace and span IDs
+// and, inside a MessageHandler, the delivery metadata to every record logged
+// with a context:
+//
+//	logger := slog.New(orbslog.NewHandler(slog.NewJSONHandler(os.Stdout, nil)))
+//
+//	handler := func(ctx context.Context, delivery amqp091.Delivery) error {
+//		logger.InfoContext(ctx, "processing order")
+//		return nil
+//	}
+//
+// PublishErrorLogger and NackLogger plug into PublisherConfig.OnPublishError
+// and ConsumerConfig.OnNack to log failed publishes, nacks and dead-letters.
+package orbslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/startower-observability/orb/instrumentation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TraceIDKey     = "trace_id"
+	SpanIDKey      = "span_id"
+	QueueKey       = "queue"
+	ExchangeKey    = "exchange"
+	RoutingKeyKey  = "routing_key"
+	MessageIDKey   = "message_id"
+	DeliveryTagKey = "delivery_tag"
+	RedeliveredKey = "redelivered"
+)
+
+// Handler adds the context attributes at the root of every record, outside
+// any groups opened with WithGroup, so they can be correlated by key.
+type Handler struct {
+	next slog.Handler
+	// grouped holds the WithGroup and WithAttrs calls made since the first
+	// group. They are applied to next after the context attributes.
+	grouped []groupOrAttrs
+}
+
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	var attrs []slog.Attr
+	if ctx != nil {
+		attrs = ContextAttrs(ctx)
+	}
+	if len(h.grouped) == 0 {
+		if len(attrs) > 0 {
+			record = record.Clone()
+			record.AddAttrs(attrs...)
+		}
+		return h.next.Handle(ctx, record)
+	}
+
+	next := h.next
+	if len(attrs) > 0 {
+		next = next.WithAttrs(attrs)
+	}
+	for _, g := range h.grouped {
+		if g.group != "" {
+			next = next.WithGroup(g.group)
+		} else {
+			next = next.WithAttrs(g.attrs)
+		}
+	}
+	return next.Handle(ctx, record)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(h.grouped) == 0 {
+		return &Handler{next: h.next.WithAttrs(attrs)}
+	}
+	return h.with(groupOrAttrs{attrs: attrs})
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(groupOrAttrs{group: name})
+}
+
+func (h *Handler) with(g groupOrAttrs) *Handler {
+	grouped := make([]groupOrAttrs, len(h.grouped), len(h.grouped)+1)
+	copy(grouped, h.grouped)
+	return &Handler{next: h.next, grouped: append(grouped, g)}
+}
+
+// ContextAttrs returns the trace and delivery attributes carried by ctx.
+func ContextAttrs(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		attrs = append(attrs,
+			slog.String(TraceIDKey, sc.TraceID().String()),
+			slog.String(SpanIDKey, sc.SpanID().String()),
+		)
+	}
+
+	if info, ok := instrumentation.DeliveryInfoFromContext(ctx); ok {
+		if info.Queue != "" {
+			attrs = append(attrs, slog.String(QueueKey, info.Queue))
+		}
+		if info.Exchange != "" {
+			attrs = append(attrs, slog.String(ExchangeKey, info.Exchange))
+		}
+		if info.RoutingKey != "" {
+			attrs = append(attrs, slog.String(RoutingKeyKey, info.RoutingKey))
+		}
+		if info.MessageID != "" {
+			attrs = append(attrs, slog.String(MessageIDKey, info.MessageID))
+		}
+		attrs = append(attrs,
+			slog.Uint64(DeliveryTagKey, info.DeliveryTag),
+			slog.Bool(RedeliveredKey, info.Redelivered),
+		)
+	}
+
+	return attrs
+}
@@ -0,0 +1,155 @@
+package orbslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	buf := &bytes.Buffer{}
+	return slog.New(NewHandler(slog.NewJSONHandler(buf, nil))), buf
+}
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]interface{} {
+	t.Helper()
+
+	record := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode log record %q: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestHandlerAddsTraceAndDeliveryAttributes(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	ctx = instrumentation.ContextWithDeliveryInfo(ctx, instrumentation.DeliveryInfo{
+		Queue:       "orders",
+		RoutingKey:  "order.created",
+		MessageID:   "msg-1",
+		DeliveryTag: 7,
+		Redelivered: true,
+	})
+
+	logger.InfoContext(ctx, "processing")
+	record := decodeRecord(t, buf)
+
+	want := map[string]interface{}{
+		TraceIDKey:     sc.TraceID().String(),
+		SpanIDKey:      sc.SpanID().String(),
+		QueueKey:       "orders",
+		RoutingKeyKey:  "order.created",
+		MessageIDKey:   "msg-1",
+		DeliveryTagKey: float64(7),
+		RedeliveredKey: true,
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("record[%q] = %v, want %v", key, record[key], value)
+		}
+	}
+}
+
+func TestHandlerKeepsContextAttributesAtRoot(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	logger.With("service", "billing").WithGroup("req").With("id", "r-1").InfoContext(ctx, "processing", "status", 200)
+	record := decodeRecord(t, buf)
+
+	if record[TraceIDKey] != sc.TraceID().String() {
+		t.Errorf("record[%q] = %v, want %v", TraceIDKey, record[TraceIDKey], sc.TraceID())
+	}
+	if record["service"] != "billing" {
+		t.Errorf("record[service] = %v, want billing", record["service"])
+	}
+	group, ok := record["req"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("record[req] = %v, want a group", record["req"])
+	}
+	if group["id"] != "r-1" || group["status"] != float64(200) {
+		t.Errorf("group = %v, want id and status", group)
+	}
+	if _, ok := group[TraceIDKey]; ok {
+		t.Error("trace ID should not be nested in the group")
+	}
+}
+
+func TestHandlerWithoutContext(t *testing.T) {
+	logger, buf := newTestLogger()
+
+	logger.Info("plain")
+	record := decodeRecord(t, buf)
+
+	if _, ok := record[TraceIDKey]; ok {
+		t.Error("records without a span should not carry a trace ID")
+	}
+}
+
+func TestConsumerPopulatesDeliveryInfo(t *testing.T) {
+	logger, buf := newTestLogger()
+	consumer := instrumentation.NewDefaultConsumer()
+
+	handler := func(ctx context.Context, delivery amqp091.Delivery) error {
+		logger.InfoContext(ctx, "handled")
+		return nil
+	}
+	delivery := amqp091.Delivery{Acknowledger: noopAcknowledger{}, MessageId: "msg-9", DeliveryTag: 3}
+	if err := consumer.ProcessDelivery(context.Background(), "payments", delivery, handler); err != nil {
+		t.Fatalf("ProcessDelivery() error = %v", err)
+	}
+
+	record := decodeRecord(t, buf)
+	if record[QueueKey] != "payments" || record[MessageIDKey] != "msg-9" {
+		t.Errorf("record = %v, want delivery metadata", record)
+	}
+}
+
+func TestNackLogger(t *testing.T) {
+	logger, buf := newTestLogger()
+	hook := NackLogger(logger)
+
+	hook(context.Background(), "orders", &amqp091.Delivery{}, false, errors.New("invalid payload"))
+	record := decodeRecord(t, buf)
+
+	if record["level"] != "ERROR" || record["msg"] != "message dead-lettered" {
+		t.Errorf("record = %v, want dead-letter error", record)
+	}
+}
+
+func TestPublishErrorLogger(t *testing.T) {
+	logger, buf := newTestLogger()
+	hook := PublishErrorLogger(logger)
+
+	hook(context.Background(), "orders", "order.created", &amqp091.Publishing{MessageId: "msg-1"}, errors.New("channel closed"))
+	record := decodeRecord(t, buf)
+
+	if record[ExchangeKey] != "orders" || record["error"] != "channel closed" {
+		t.Errorf("record = %v, want publish failure", record)
+	}
+}
+
+type noopAcknowledger struct{}
+
+func (noopAcknowledger) Ack(tag uint64, multiple bool) error                { return nil }
+func (noopAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error { return nil }
+func (noopAcknowledger) Reject(tag uint64, requeue bool) error              { return nil }
@@ -0,0 +1,39 @@
+package orbslog
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// PublishErrorLogger returns a PublisherConfig.OnPublishError hook that logs
+// failed publishes at error level.
+func PublishErrorLogger(logger *slog.Logger) func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, err error) {
+	return func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, err error) {
+		attrs := []slog.Attr{
+			slog.String(ExchangeKey, exchange),
+			slog.String(RoutingKeyKey, routingKey),
+			slog.Any("error", err),
+		}
+		if msg.MessageId != "" {
+			attrs = append(attrs, slog.String(MessageIDKey, msg.MessageId))
+		}
+		logger.LogAttrs(ctx, slog.LevelError, "message publish failed", attrs...)
+	}
+}
+
+// NackLogger returns a ConsumerConfig.OnNack hook. Requeued messages are logged
+// at warn level and dead-lettered messages at error level.
+func NackLogger(logger *slog.Logger) func(ctx context.Context, queueName string, delivery *amqp091.Delivery, requeue bool, err error) {
+	return func(ctx context.Context, queueName string, delivery *amqp091.Delivery, requeue bool, err error) {
+		level, msg := slog.LevelWarn, "message nacked"
+		if !requeue {
+			level, msg = slog.LevelError, "message dead-lettered"
+		}
+		logger.LogAttrs(ctx, level, msg,
+			slog.Bool("requeue", requeue),
+			slog.Any("error", err),
+		)
+	}
+}
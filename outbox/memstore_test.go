@@ -0,0 +1,85 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// memStore is an in-memory Store for relay tests. It ignores transactions
+// and can inject failures; SQLStore itself is tested against SQLite in the
+// sqlitetest module.
+type memStore struct {
+	mu        sync.Mutex
+	messages  []*memMessage
+	failMark  error
+	failFetch error
+}
+
+type memMessage struct {
+	Message
+	lastError string
+	published bool
+}
+
+func (s *memStore) Add(ctx context.Context, tx Tx, msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, &memMessage{Message: *msg})
+	return nil
+}
+
+func (s *memStore) Pending(ctx context.Context, limit int) ([]*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failFetch != nil {
+		return nil, s.failFetch
+	}
+	var pending []*Message
+	for _, msg := range s.messages {
+		if !msg.published && len(pending) < limit {
+			copied := msg.Message
+			pending = append(pending, &copied)
+		}
+	}
+	return pending, nil
+}
+
+func (s *memStore) MarkPublished(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failMark != nil {
+		return s.failMark
+	}
+	if msg := s.find(id); msg != nil {
+		msg.published = true
+	}
+	return nil
+}
+
+func (s *memStore) MarkFailed(ctx context.Context, id string, cause error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg := s.find(id); msg != nil {
+		msg.Attempts++
+		msg.lastError = cause.Error()
+	}
+	return nil
+}
+
+func (s *memStore) message(id string) memMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg := s.find(id); msg != nil {
+		return *msg
+	}
+	return memMessage{}
+}
+
+func (s *memStore) find(id string) *memMessage {
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			return msg
+		}
+	}
+	return nil
+}
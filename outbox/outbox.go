@@ -0,0 +1,118 @@
+// Package outbox implements the transactional outbox pattern: messages are
+// written to a Store in the same database transaction as the business data
+// and a Relay publishes them to RabbitMQ afterwards, so a commit can no
+// longer succeed while its messages are lost.
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	MessageIDKey = "messaging.orb.outbox.message_id"
+	AttemptKey   = "messaging.orb.outbox.attempt"
+)
+
+// Message is a publishing waiting in the outbox.
+type Message struct {
+	ID         string
+	Exchange   string
+	RoutingKey string
+	Publishing amqp091.Publishing
+	// TraceContext is the propagated context of the request that enqueued
+	// the message. The relay links its publish span to it.
+	TraceContext map[string]string
+	CreatedAt    time.Time
+	// Attempts counts failed publishes.
+	Attempts int
+}
+
+// Tx is the transaction a message is written in. *sql.Tx satisfies it.
+type Tx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Store persists outbox messages. Add must write msg within tx, so it is
+// committed or rolled back with the caller's changes. Pending returns
+// unpublished messages in the order they were added.
+type Store interface {
+	Add(ctx context.Context, tx Tx, msg *Message) error
+	Pending(ctx context.Context, limit int) ([]*Message, error)
+	MarkPublished(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, cause error) error
+}
+
+type Config struct {
+	Store Store
+	// Propagator serialises the trace context of enqueued messages.
+	// Defaults to the global propagator.
+	Propagator propagation.TextMapPropagator
+}
+
+type Outbox struct {
+	config Config
+	now    func() time.Time
+}
+
+func New(config Config) *Outbox {
+	if config.Propagator == nil {
+		config.Propagator = otel.GetTextMapPropagator()
+	}
+	return &Outbox{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Enqueue writes msg to the outbox within tx and returns its ID. The trace
+// context of ctx is stored with the message.
+func (o *Outbox) Enqueue(
+	ctx context.Context,
+	tx Tx,
+	exchange, routingKey string,
+	msg amqp091.Publishing,
+) (string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+
+	carrier := propagation.MapCarrier{}
+	o.config.Propagator.Inject(ctx, carrier)
+
+	message := &Message{
+		ID:           id,
+		Exchange:     exchange,
+		RoutingKey:   routingKey,
+		Publishing:   msg,
+		TraceContext: carrier,
+		CreatedAt:    o.now(),
+	}
+	if err := o.config.Store.Add(ctx, tx, message); err != nil {
+		return "", fmt.Errorf("failed to add message to outbox: %w", err)
+	}
+
+	trace.SpanFromContext(ctx).AddEvent("outbox.enqueue", trace.WithAttributes(
+		attribute.String(MessageIDKey, id),
+	))
+	return id, nil
+}
+
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate outbox message id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type published struct {
+	routingKey string
+	msg        amqp091.Publishing
+}
+
+func newTestRelay(t *testing.T, store Store, sent *[]published, fail *error) (*Relay, *tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	publisher := instrumentation.NewPublisher(instrumentation.PublisherConfig{
+		Tracer: provider.Tracer("test"),
+		Propagator: instrumentation.NewPropagatorWithConfig(instrumentation.PropagatorConfig{
+			TextMapPropagator: propagation.TraceContext{},
+		}),
+		Interceptors: []instrumentation.PublishInterceptor{
+			func(ctx context.Context, exchange, routingKey string, msg *amqp091.Publishing, next instrumentation.PublishFunc) error {
+				if *fail != nil {
+					return *fail
+				}
+				*sent = append(*sent, published{routingKey: routingKey, msg: *msg})
+				return nil
+			},
+		},
+	})
+	relay := NewRelay(RelayConfig{
+		Store:     store,
+		Publisher: publisher,
+		// The interceptor above never calls next, so the channel is unused.
+		Channel:    &amqp091.Channel{},
+		Propagator: propagation.TraceContext{},
+	})
+	return relay, recorder, provider
+}
+
+func TestRelayLinksOriginSpan(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{}
+
+	var sent []published
+	var fail error
+	relay, recorder, provider := newTestRelay(t, store, &sent, &fail)
+	box := New(Config{Store: store, Propagator: propagation.TraceContext{}})
+
+	reqCtx, reqSpan := provider.Tracer("test").Start(ctx, "POST /orders")
+	var ids []string
+	for _, key := range []string{"order.created", "order.paid"} {
+		id, err := box.Enqueue(reqCtx, nil, "orders", key, amqp091.Publishing{})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	reqSpan.End()
+
+	fail = errors.New("connection reset")
+	if n, err := relay.Flush(ctx); err == nil || n != 0 {
+		t.Fatalf("Flush() = %d, %v, want failure", n, err)
+	}
+	if msg := store.message(ids[0]); msg.Attempts != 1 || msg.lastError != "connection reset" {
+		t.Errorf("failed message = %+v", msg)
+	}
+
+	fail = nil
+	if n, err := relay.Flush(ctx); err != nil || n != 2 {
+		t.Fatalf("Flush() = %d, %v, want 2", n, err)
+	}
+	if len(sent) != 2 || sent[0].routingKey != "order.created" || sent[1].routingKey != "order.paid" {
+		t.Fatalf("sent = %+v, want messages in order", sent)
+	}
+	if pending, _ := store.Pending(ctx, 10); len(pending) != 0 {
+		t.Errorf("pending after flush = %d, want 0", len(pending))
+	}
+
+	spans := recorder.Ended()
+	last := spans[len(spans)-1]
+	if links := last.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != reqSpan.SpanContext().SpanID() {
+		t.Errorf("relay span links = %v, want link to request span", links)
+	}
+	if last.SpanContext().TraceID() == reqSpan.SpanContext().TraceID() {
+		t.Error("relay span should start a new trace")
+	}
+	for _, attr := range last.Attributes() {
+		if attr.Key == AttemptKey && attr.Value != attribute.IntValue(1) {
+			t.Errorf("attempt = %v, want 1", attr.Value.Emit())
+		}
+	}
+	if sent[0].msg.Headers["traceparent"] == nil {
+		t.Error("relay should inject its own trace context")
+	}
+}
+
+func TestRelayRunStops(t *testing.T) {
+	var sent []published
+	var fail error
+	relay, _, _ := newTestRelay(t, &memStore{}, &sent, &fail)
+	relay.config.Interval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := relay.run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("run() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestRelayRequiresChannel(t *testing.T) {
+	relay := NewRelay(RelayConfig{Store: &memStore{}})
+
+	if err := relay.Run(context.Background()); !errors.Is(err, ErrNoChannel) {
+		t.Errorf("Run() error = %v, want ErrNoChannel", err)
+	}
+	if _, err := relay.Flush(context.Background()); !errors.Is(err, ErrNoChannel) {
+		t.Errorf("Flush() error = %v, want ErrNoChannel", err)
+	}
+}
+
+func TestRelayStopsOnStoreFailure(t *testing.T) {
+	ctx := context.Background()
+	store := &memStore{}
+	var sent []published
+	var fail error
+	relay, _, _ := newTestRelay(t, store, &sent, &fail)
+	box := New(Config{Store: store})
+	for _, key := range []string{"order.created", "order.paid"} {
+		if _, err := box.Enqueue(ctx, nil, "orders", key, amqp091.Publishing{}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	store.failFetch = errors.New("database down")
+	if _, err := relay.Flush(ctx); !errors.Is(err, store.failFetch) {
+		t.Errorf("Flush() error = %v, want %v", err, store.failFetch)
+	}
+
+	store.failFetch = nil
+	store.failMark = errors.New("database down")
+	n, err := relay.Flush(ctx)
+	if !errors.Is(err, store.failMark) || n != 0 {
+		t.Errorf("Flush() = %d, %v, want 0, %v", n, err, store.failMark)
+	}
+	if len(sent) != 1 {
+		t.Errorf("sent = %d, want the batch to stop after the unmarked message", len(sent))
+	}
+}
@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/instrumentation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrNacked    = errors.New("outbox message was nacked by the broker")
+	ErrNoChannel = errors.New("outbox relay has no channel")
+)
+
+const (
+	DefaultBatchSize = 100
+	DefaultInterval  = time.Second
+)
+
+type RelayConfig struct {
+	Store Store
+	// Publisher publishes the messages. Defaults to a default Publisher.
+	Publisher *instrumentation.Publisher
+	// Channel is put in confirm mode by Run. Required.
+	Channel *amqp091.Channel
+	// Propagator must match the one used by the Outbox. Defaults to the
+	// global propagator.
+	Propagator propagation.TextMapPropagator
+	BatchSize  int
+	Interval   time.Duration
+	// Logger receives relay failures. Logging is disabled when nil.
+	Logger *slog.Logger
+}
+
+// Relay publishes pending outbox messages with publisher confirms. Messages
+// are published in order and a failed message stops its batch, so it is
+// retried before anything enqueued after it.
+//
+// Pending messages are not claimed, so only one Relay may run per outbox
+// table; concurrent relays would publish every message more than once.
+type Relay struct {
+	config RelayConfig
+}
+
+func NewRelay(config RelayConfig) *Relay {
+	if config.Publisher == nil {
+		config.Publisher = instrumentation.NewDefaultPublisher()
+	}
+	if config.Propagator == nil {
+		config.Propagator = otel.GetTextMapPropagator()
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	return &Relay{config: config}
+}
+
+// Run flushes the outbox every Interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) error {
+	if r.config.Channel == nil {
+		return ErrNoChannel
+	}
+	if err := r.config.Channel.Confirm(false); err != nil {
+		return fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return r.run(ctx)
+}
+
+func (r *Relay) run(ctx context.Context) error {
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := r.Flush(ctx)
+			if err != nil && r.config.Logger != nil && ctx.Err() == nil {
+				r.config.Logger.ErrorContext(ctx, "outbox relay failed", slog.Any("error", err))
+			}
+			// Keep draining while full batches are published.
+			if err != nil || n < r.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending messages and returns how many were
+// published.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	if r.config.Channel == nil {
+		return 0, ErrNoChannel
+	}
+	messages, err := r.config.Store.Pending(ctx, r.config.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load pending outbox messages: %w", err)
+	}
+
+	for i, msg := range messages {
+		if err := r.publish(ctx, msg); err != nil {
+			if markErr := r.config.Store.MarkFailed(ctx, msg.ID, err); markErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to mark outbox message failed: %w", markErr))
+			}
+			return i, fmt.Errorf("failed to publish outbox message %s: %w", msg.ID, err)
+		}
+		if err := r.config.Store.MarkPublished(ctx, msg.ID); err != nil {
+			return i, fmt.Errorf("failed to mark outbox message %s published: %w", msg.ID, err)
+		}
+	}
+	return len(messages), nil
+}
+
+func (r *Relay) publish(ctx context.Context, msg *Message) error {
+	origin := r.config.Propagator.Extract(context.Background(), propagation.MapCarrier(msg.TraceContext))
+
+	ctx = baggage.ContextWithBaggage(ctx, baggage.FromContext(origin))
+	opts := []trace.SpanStartOption{trace.WithAttributes(
+		attribute.String(MessageIDKey, msg.ID),
+		attribute.Int(AttemptKey, msg.Attempts+1),
+	)}
+	if sc := trace.SpanContextFromContext(origin); sc.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: sc}))
+	}
+	ctx = instrumentation.ContextWithSpanStartOptions(ctx, opts...)
+
+	confirmation, err := r.config.Publisher.PublishWithConfirm(
+		ctx, r.config.Channel, msg.Exchange, msg.RoutingKey, false, false, msg.Publishing,
+	)
+	if err != nil {
+		return err
+	}
+	if confirmation == nil {
+		return nil
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
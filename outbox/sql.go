@@ -0,0 +1,157 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/startower-observability/orb/internal"
+)
+
+const DefaultTable = "orb_outbox"
+
+// QuestionPlaceholder formats bind parameters as ?, as used by SQLite and
+// MySQL drivers.
+func QuestionPlaceholder(int) string { return "?" }
+
+// DollarPlaceholder formats bind parameters as $1, $2, ... as used by
+// PostgreSQL drivers.
+func DollarPlaceholder(n int) string { return "$" + strconv.Itoa(n) }
+
+type SQLStoreConfig struct {
+	// Table defaults to orb_outbox.
+	Table string
+	// Placeholder formats the nth (1-based) bind parameter. Defaults to
+	// QuestionPlaceholder.
+	Placeholder func(n int) string
+}
+
+// SQLStore is a Store backed by database/sql. Messages are stored as JSON
+// text, so it works with any driver; header values round-trip as strings,
+// booleans, int64 or float64.
+type SQLStore struct {
+	db     *sql.DB
+	config SQLStoreConfig
+	now    func() time.Time
+}
+
+func NewSQLStore(db *sql.DB, config SQLStoreConfig) *SQLStore {
+	if config.Table == "" {
+		config.Table = DefaultTable
+	}
+	if config.Placeholder == nil {
+		config.Placeholder = QuestionPlaceholder
+	}
+	return &SQLStore{
+		db:     db,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// CreateTable creates the outbox table if it does not exist.
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	query := `CREATE TABLE IF NOT EXISTS ` + s.config.Table + ` (
+	id VARCHAR(64) PRIMARY KEY,
+	exchange VARCHAR(255) NOT NULL,
+	routing_key VARCHAR(255) NOT NULL,
+	message TEXT NOT NULL,
+	trace_context TEXT NOT NULL,
+	created_at BIGINT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	published_at BIGINT
+)`
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Add(ctx context.Context, tx Tx, msg *Message) error {
+	publishing, err := json.Marshal(msg.Publishing)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox message: %w", err)
+	}
+	traceContext, err := json.Marshal(msg.TraceContext)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox trace context: %w", err)
+	}
+
+	query := `INSERT INTO ` + s.config.Table +
+		` (id, exchange, routing_key, message, trace_context, created_at, attempts) VALUES (` +
+		s.placeholders(1, 7) + `)`
+	_, err = tx.ExecContext(ctx, query,
+		msg.ID, msg.Exchange, msg.RoutingKey, string(publishing), string(traceContext),
+		msg.CreatedAt.UnixNano(), msg.Attempts,
+	)
+	return err
+}
+
+func (s *SQLStore) Pending(ctx context.Context, limit int) ([]*Message, error) {
+	query := `SELECT id, exchange, routing_key, message, trace_context, created_at, attempts FROM ` +
+		s.config.Table + ` WHERE published_at IS NULL ORDER BY created_at, id LIMIT ` + strconv.Itoa(limit)
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*Message
+	for rows.Next() {
+		var (
+			msg                   Message
+			publishing, traceJSON string
+			createdAt             int64
+		)
+		if err := rows.Scan(&msg.ID, &msg.Exchange, &msg.RoutingKey, &publishing, &traceJSON, &createdAt, &msg.Attempts); err != nil {
+			return nil, err
+		}
+		if err := internal.UnmarshalPublishing([]byte(publishing), &msg.Publishing); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox message %s: %w", msg.ID, err)
+		}
+		if err := json.Unmarshal([]byte(traceJSON), &msg.TraceContext); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox trace context %s: %w", msg.ID, err)
+		}
+		msg.CreatedAt = time.Unix(0, createdAt)
+		messages = append(messages, &msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLStore) MarkPublished(ctx context.Context, id string) error {
+	query := `UPDATE ` + s.config.Table + ` SET published_at = ` + s.config.Placeholder(1) +
+		` WHERE id = ` + s.config.Placeholder(2)
+	_, err := s.db.ExecContext(ctx, query, s.now().UnixNano(), id)
+	return err
+}
+
+func (s *SQLStore) MarkFailed(ctx context.Context, id string, cause error) error {
+	query := `UPDATE ` + s.config.Table + ` SET attempts = attempts + 1, last_error = ` +
+		s.config.Placeholder(1) + ` WHERE id = ` + s.config.Placeholder(2)
+	_, err := s.db.ExecContext(ctx, query, cause.Error(), id)
+	return err
+}
+
+// DeletePublished removes messages published before the given time.
+func (s *SQLStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM ` + s.config.Table + ` WHERE published_at IS NOT NULL AND published_at < ` +
+		s.config.Placeholder(1)
+	result, err := s.db.ExecContext(ctx, query, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (s *SQLStore) placeholders(from, to int) string {
+	params := make([]string, 0, to-from+1)
+	for n := from; n <= to; n++ {
+		params = append(params, s.config.Placeholder(n))
+	}
+	return strings.Join(params, ", ")
+}
@@ -0,0 +1,4 @@
+// Package sqlitetest runs the outbox SQLStore against an embedded SQLite
+// database. It is a separate module so the SQLite driver does not become a
+// dependency of orb.
+package sqlitetest
@@ -0,0 +1,150 @@
+package sqlitetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/outbox"
+	"go.opentelemetry.io/otel/propagation"
+	_ "modernc.org/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func enqueue(t *testing.T, db *sql.DB, box *outbox.Outbox, routingKey string, msg amqp091.Publishing, commit bool) string {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	id, err := box.Enqueue(ctx, tx, "orders", routingKey, msg)
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if commit {
+		err = tx.Commit()
+	} else {
+		err = tx.Rollback()
+	}
+	if err != nil {
+		t.Fatalf("finishing transaction: %v", err)
+	}
+	return id
+}
+
+func TestSQLStore(t *testing.T) {
+	tests := []struct {
+		name   string
+		config outbox.SQLStoreConfig
+	}{
+		{"question placeholders", outbox.SQLStoreConfig{}},
+		{"dollar placeholders", outbox.SQLStoreConfig{Table: "orders_outbox", Placeholder: outbox.DollarPlaceholder}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := openDB(t)
+			store := outbox.NewSQLStore(db, tt.config)
+			for i := 0; i < 2; i++ {
+				if err := store.CreateTable(ctx); err != nil {
+					t.Fatalf("CreateTable() error = %v", err)
+				}
+			}
+			box := outbox.New(outbox.Config{Store: store, Propagator: propagation.TraceContext{}})
+
+			enqueue(t, db, box, "order.cancelled", amqp091.Publishing{Body: []byte("rolled back")}, false)
+			headers := amqp091.Table{
+				"retries": int32(3),
+				"tenant":  "acme",
+				"urgent":  true,
+				"weight":  1.5,
+				"nested":  amqp091.Table{"depth": int64(2)},
+			}
+			first := enqueue(t, db, box, "order.created", amqp091.Publishing{
+				Headers:     headers,
+				ContentType: "application/json",
+				Body:        []byte(`{"id":1}`),
+			}, true)
+			second := enqueue(t, db, box, "order.paid", amqp091.Publishing{}, true)
+			enqueue(t, db, box, "order.shipped", amqp091.Publishing{}, true)
+
+			pending, err := store.Pending(ctx, 2)
+			if err != nil {
+				t.Fatalf("Pending() error = %v", err)
+			}
+			if len(pending) != 2 || pending[0].ID != first || pending[1].ID != second {
+				t.Fatalf("pending = %+v, want the first two committed messages in order", pending)
+			}
+			msg := pending[0]
+			if msg.Exchange != "orders" || msg.RoutingKey != "order.created" || msg.Attempts != 0 {
+				t.Errorf("message = %+v", msg)
+			}
+			if msg.Publishing.ContentType != "application/json" || string(msg.Publishing.Body) != `{"id":1}` {
+				t.Errorf("publishing = %+v", msg.Publishing)
+			}
+			got := msg.Publishing.Headers
+			if got["retries"] != int64(3) || got["tenant"] != "acme" || got["urgent"] != true || got["weight"] != 1.5 {
+				t.Errorf("headers = %v", got)
+			}
+			if nested, _ := got["nested"].(amqp091.Table); nested["depth"] != int64(2) {
+				t.Errorf("nested header = %v", got["nested"])
+			}
+			if msg.CreatedAt.IsZero() || time.Since(msg.CreatedAt) > time.Minute {
+				t.Errorf("CreatedAt = %v", msg.CreatedAt)
+			}
+
+			if err := store.MarkFailed(ctx, first, errors.New("connection reset")); err != nil {
+				t.Fatalf("MarkFailed() error = %v", err)
+			}
+			table := tt.config.Table
+			if table == "" {
+				table = outbox.DefaultTable
+			}
+			var lastError string
+			if err := db.QueryRowContext(ctx, "SELECT last_error FROM "+table+" WHERE id = ?", first).Scan(&lastError); err != nil {
+				t.Fatalf("reading last_error: %v", err)
+			}
+			if lastError != "connection reset" {
+				t.Errorf("last_error = %q", lastError)
+			}
+
+			if err := store.MarkPublished(ctx, second); err != nil {
+				t.Fatalf("MarkPublished() error = %v", err)
+			}
+			pending, err = store.Pending(ctx, 10)
+			if err != nil {
+				t.Fatalf("Pending() error = %v", err)
+			}
+			if len(pending) != 2 || pending[0].ID != first || pending[0].Attempts != 1 {
+				t.Fatalf("pending = %+v, want the failed message first with one attempt", pending)
+			}
+			for _, msg := range pending {
+				if msg.ID == second {
+					t.Error("published message is still pending")
+				}
+			}
+
+			if n, err := store.DeletePublished(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+				t.Errorf("DeletePublished(past) = %d, %v, want 0", n, err)
+			}
+			if n, err := store.DeletePublished(ctx, time.Now().Add(time.Hour)); err != nil || n != 1 {
+				t.Errorf("DeletePublished(future) = %d, %v, want 1", n, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package sampling provides an OpenTelemetry sampler that understands the
+// messaging attributes emitted by orb publish and consume spans.
+//
+// Rules select a sampling ratio per queue, exchange or routing key pattern.
+// Redelivered and dead-lettered messages can always be sampled, and a message
+// published with the x-orb-force-sample header is sampled on every hop:
+//
+//	sampler := sampling.NewSampler(sampling.Config{
+//		Rules: []sampling.Rule{
+//			{Queue: "health-*", Ratio: 0},
+//			{Exchange: "orders", RoutingKey: "order.*", Ratio: 0.25},
+//		},
+//		AlwaysSampleRedelivered:  true,
+//		AlwaysSampleDeadLettered: true,
+//	})
+//	tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sampler))
+package sampling
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Rule applies Ratio to messaging spans whose destination matches every
+// non-empty pattern. Patterns use path.Match syntax.
+type Rule struct {
+	Queue      string
+	Exchange   string
+	RoutingKey string
+	Ratio      float64
+}
+
+type Config struct {
+	// Rules are evaluated in order; the first match decides.
+	Rules []Rule
+	// AlwaysSampleRedelivered samples consume spans for redelivered messages.
+	AlwaysSampleRedelivered bool
+	// AlwaysSampleDeadLettered samples consume spans for messages carrying an
+	// x-death header.
+	AlwaysSampleDeadLettered bool
+	// ParentBased makes rule decisions defer to a valid parent span. Forced,
+	// redelivered and dead-lettered decisions still take precedence.
+	ParentBased bool
+	// Fallback samples non-RabbitMQ spans and messaging spans that match no
+	// rule. Defaults to ParentBased(AlwaysSample()).
+	Fallback sdktrace.Sampler
+}
+
+type rule struct {
+	Rule
+	sampler sdktrace.Sampler
+}
+
+type sampler struct {
+	config Config
+	rules  []rule
+}
+
+func NewSampler(config Config) sdktrace.Sampler {
+	if config.Fallback == nil {
+		config.Fallback = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+
+	rules := make([]rule, 0, len(config.Rules))
+	for _, r := range config.Rules {
+		rules = append(rules, rule{Rule: r, sampler: sdktrace.TraceIDRatioBased(r.Ratio)})
+	}
+
+	return &sampler{config: config, rules: rules}
+}
+
+func (s *sampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	dest, ok := destinationFromAttributes(p.Attributes)
+	if !ok {
+		return s.config.Fallback.ShouldSample(p)
+	}
+
+	if dest.forceSample ||
+		(s.config.AlwaysSampleRedelivered && dest.redelivered) ||
+		(s.config.AlwaysSampleDeadLettered && dest.deadLettered) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	for _, r := range s.rules {
+		if !r.matches(dest) {
+			continue
+		}
+		if s.config.ParentBased {
+			if parent := trace.SpanContextFromContext(p.ParentContext); parent.IsValid() {
+				decision := sdktrace.Drop
+				if parent.IsSampled() {
+					decision = sdktrace.RecordAndSample
+				}
+				return sdktrace.SamplingResult{Decision: decision, Tracestate: parent.TraceState()}
+			}
+		}
+		return r.sampler.ShouldSample(p)
+	}
+
+	return s.config.Fallback.ShouldSample(p)
+}
+
+func (s *sampler) Description() string {
+	return fmt.Sprintf("OrbMessagingSampler{rules:%d,fallback:%s}", len(s.rules), s.config.Fallback.Description())
+}
+
+func (r rule) matches(dest destination) bool {
+	return matchPattern(r.Queue, dest.queue) &&
+		matchPattern(r.Exchange, dest.exchange) &&
+		matchPattern(r.RoutingKey, dest.routingKey)
+}
+
+func matchPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+type destination struct {
+	queue        string
+	exchange     string
+	routingKey   string
+	redelivered  bool
+	deadLettered bool
+	forceSample  bool
+}
+
+func destinationFromAttributes(attrs []attribute.KeyValue) (destination, bool) {
+	var (
+		dest      destination
+		system    string
+		name      string
+		kind      string
+		exchange  string
+		operation string
+	)
+
+	for _, attr := range attrs {
+		switch attr.Key {
+		case internal.MessagingSystem:
+			system = attr.Value.AsString()
+		case internal.MessagingDestination, internal.MessagingDestinationName:
+			name = attr.Value.AsString()
+		case internal.MessagingDestinationKind:
+			kind = attr.Value.AsString()
+		case internal.MessagingRabbitMQExchange:
+			exchange = attr.Value.AsString()
+		case internal.MessagingRabbitMQRoutingKey, internal.MessagingRabbitMQDestinationRoutingKey:
+			dest.routingKey = attr.Value.AsString()
+		case internal.MessagingOperationType:
+			operation = attr.Value.AsString()
+		case internal.MessagingRabbitMQRedelivered:
+			dest.redelivered = attr.Value.AsBool()
+		case internal.MessagingRabbitMQDeadLettered:
+			dest.deadLettered = attr.Value.AsBool()
+		case internal.MessagingOrbForceSample:
+			dest.forceSample = attr.Value.AsBool()
+		}
+	}
+
+	if system != internal.SystemRabbitMQ {
+		return destination{}, false
+	}
+
+	// Publish spans name the exchange as their destination; consume spans
+	// name the queue and report the exchange separately. The stable
+	// conventions have no destination kind, so publish spans to a named
+	// exchange report it as the exchange instead.
+	switch {
+	case kind == internal.DestinationKindTopic:
+		dest.exchange = name
+	case kind == "" && operation == internal.OperationTypeSend && exchange != "":
+		dest.exchange = exchange
+	default:
+		dest.queue = name
+		dest.exchange = exchange
+	}
+
+	return dest, true
+}
@@ -0,0 +1,141 @@
+package sampling
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/startower-observability/orb/internal"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var testTraceID = trace.TraceID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 1, 2, 3, 4, 5, 6, 7, 8}
+
+func sample(s sdktrace.Sampler, ctx context.Context, attrs []attribute.KeyValue) sdktrace.SamplingDecision {
+	return s.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: ctx,
+		TraceID:       testTraceID,
+		Name:          "test",
+		Kind:          trace.SpanKindConsumer,
+		Attributes:    attrs,
+	}).Decision
+}
+
+func TestSamplerRules(t *testing.T) {
+	s := NewSampler(Config{
+		Rules: []Rule{
+			{Queue: "health-*", Ratio: 0},
+			{Exchange: "orders", RoutingKey: "order.*", Ratio: 1},
+			{Exchange: "orders", Ratio: 0},
+		},
+		Fallback: sdktrace.NeverSample(),
+	})
+
+	tests := []struct {
+		name  string
+		attrs []attribute.KeyValue
+		want  sdktrace.SamplingDecision
+	}{
+		{
+			name:  "health queue dropped",
+			attrs: internal.GetConsumeAttributes("health-check", &amqp091.Delivery{}),
+			want:  sdktrace.Drop,
+		},
+		{
+			name:  "publish to matching routing key",
+			attrs: internal.GetPublishAttributes("orders", "order.created", &amqp091.Publishing{}),
+			want:  sdktrace.RecordAndSample,
+		},
+		{
+			name:  "consume from exchange with matching routing key",
+			attrs: internal.GetConsumeAttributes("billing", &amqp091.Delivery{Exchange: "orders", RoutingKey: "order.paid"}),
+			want:  sdktrace.RecordAndSample,
+		},
+		{
+			name:  "publish to other routing key",
+			attrs: internal.GetPublishAttributes("orders", "invoice.created", &amqp091.Publishing{}),
+			want:  sdktrace.Drop,
+		},
+		{
+			name:  "unmatched destination uses fallback",
+			attrs: internal.GetPublishAttributes("users", "user.created", &amqp091.Publishing{}),
+			want:  sdktrace.Drop,
+		},
+	}
+
+	for _, mode := range []internal.SemconvMode{internal.SemconvOld, internal.SemconvStable} {
+		for _, tt := range tests {
+			t.Run(mode.String()+"/"+tt.name, func(t *testing.T) {
+				attrs := internal.ConvertAttributes(mode, tt.attrs)
+				if got := sample(s, context.Background(), attrs); got != tt.want {
+					t.Errorf("ShouldSample() = %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestSamplerAlwaysSample(t *testing.T) {
+	s := NewSampler(Config{
+		Rules:                    []Rule{{Ratio: 0}},
+		AlwaysSampleRedelivered:  true,
+		AlwaysSampleDeadLettered: true,
+	})
+
+	tests := []struct {
+		name     string
+		delivery amqp091.Delivery
+		want     sdktrace.SamplingDecision
+	}{
+		{"plain delivery", amqp091.Delivery{}, sdktrace.Drop},
+		{"redelivered", amqp091.Delivery{Redelivered: true}, sdktrace.RecordAndSample},
+		{"dead-lettered", amqp091.Delivery{Headers: amqp091.Table{internal.DeathHeader: []interface{}{}}}, sdktrace.RecordAndSample},
+		{"forced", amqp091.Delivery{Headers: amqp091.Table{internal.ForceSampleHeader: "true"}}, sdktrace.RecordAndSample},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := internal.GetConsumeAttributes("orders", &tt.delivery)
+			if got := sample(s, context.Background(), attrs); got != tt.want {
+				t.Errorf("ShouldSample() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerForceSampleOverridesParent(t *testing.T) {
+	s := NewSampler(Config{Rules: []Rule{{Ratio: 0}}, ParentBased: true})
+
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: testTraceID,
+		SpanID:  trace.SpanID{1},
+		Remote:  true,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), parent)
+
+	plain := internal.GetPublishAttributes("orders", "order.created", &amqp091.Publishing{})
+	if got := sample(s, ctx, plain); got != sdktrace.Drop {
+		t.Errorf("ShouldSample() = %v, want unsampled parent honoured", got)
+	}
+
+	forced := internal.GetPublishAttributes("orders", "order.created", &amqp091.Publishing{
+		Headers: amqp091.Table{internal.ForceSampleHeader: true},
+	})
+	if got := sample(s, ctx, forced); got != sdktrace.RecordAndSample {
+		t.Errorf("ShouldSample() = %v, want forced sample", got)
+	}
+}
+
+func TestSamplerFallbackForOtherSpans(t *testing.T) {
+	s := NewSampler(Config{Rules: []Rule{{Ratio: 0}}})
+
+	if got := sample(s, context.Background(), []attribute.KeyValue{attribute.String("http.method", "GET")}); got != sdktrace.RecordAndSample {
+		t.Errorf("ShouldSample() = %v, want fallback decision", got)
+	}
+	if !strings.HasPrefix(s.Description(), "OrbMessagingSampler") {
+		t.Errorf("Description() = %q", s.Description())
+	}
+}